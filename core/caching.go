@@ -0,0 +1,73 @@
+package core
+
+import (
+	"encoding/xml"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// CachingPolicy max-age (in seconds) and immutable flag, used to build a Cache-Control header
+type CachingPolicy struct {
+	MaxAge    string `xml:"max-age,attr"`
+	Immutable string `xml:"immutable,attr"`
+}
+
+// CachingRule caching policy for public files with a matching mime type (expression, e.g. 'image/*')
+type CachingRule struct {
+	Expression string `xml:"expression,attr"`
+	CachingPolicy
+}
+
+// Caching struct, site-level caching policy (from: 'caching.xml')
+type Caching struct {
+	XMLName     xml.Name      `xml:"caching"`
+	PublicFiles []CachingRule `xml:"public-files>mime-type"`
+	Nodes       CachingPolicy `xml:"nodes"`
+}
+
+// Read read caching policy from []byte
+func (c *Caching) Read(r []byte) error {
+	return xml.Unmarshal(r, &c)
+}
+
+// PublicFile return Cache-Control header for public files of mimeType (first matching rule), "" if there is none
+func (c *Caching) PublicFile(mimeType string) string {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0]))
+
+	for _, rule := range c.PublicFiles {
+		m, err := path.Match(strings.ToLower(strings.TrimSpace(rule.Expression)), mimeType)
+		if m && err == nil {
+			return rule.CacheControl()
+		}
+	}
+
+	return ""
+}
+
+// Node return default Cache-Control header for rendered nodes, "" if there is none
+func (c *Caching) Node() string {
+	return c.Nodes.CacheControl()
+}
+
+// CacheControl return value of the Cache-Control header, "" if max-age is not set
+func (p *CachingPolicy) CacheControl() string {
+	maxAge, err := strconv.Atoi(strings.TrimSpace(p.MaxAge))
+	if err != nil || maxAge < 0 {
+		return ""
+	}
+
+	if maxAge == 0 {
+		// may be stored, but has to be revalidated every time (see ETag, Last-Modified)
+		return "no-cache"
+	}
+
+	cc := "public, max-age=" + strconv.Itoa(maxAge)
+
+	immutable := strings.ToLower(strings.TrimSpace(p.Immutable))
+	if immutable == "1" || immutable == "on" || strings.HasPrefix(immutable, "enable") || immutable == "true" {
+		cc += ", immutable"
+	}
+
+	return cc
+}
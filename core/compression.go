@@ -0,0 +1,164 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// contentCodings supported content codings in order of preference
+var contentCodings = []string{"br", "gzip"}
+
+// minCompressSize content smaller than this is not worth compressing
+const minCompressSize = 256
+
+// compressible return if content of mimeType is worth compressing, i.e. if it is not compressed already
+// (like most images, audio, video, archives)
+func compressible(mimeType string) bool {
+	m := strings.ToLower(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0]))
+
+	if strings.HasPrefix(m, "text/") || strings.HasSuffix(m, "+xml") || strings.HasSuffix(m, "+json") {
+		return true
+	}
+
+	switch m {
+	case "application/javascript", "application/x-javascript", "application/ecmascript",
+		"application/json", "application/xml", "application/wasm", "application/rtf",
+		"application/vnd.ms-fontobject", "application/x-font-ttf", "font/ttf", "font/otf",
+		"image/x-icon", "image/vnd.microsoft.icon", "image/bmp":
+		return true
+	}
+
+	return false
+}
+
+// compress return content compressed using content coding enc, either with the best (= slowest)
+// compression for content that is compressed only once, or with a faster default compression
+func compress(enc string, content []byte, best bool) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+
+	switch enc {
+	case "br":
+		level := brotli.DefaultCompression
+		if best {
+			level = brotli.BestCompression
+		}
+		w = brotli.NewWriterLevel(&buf, level)
+	case "gzip":
+		level := gzip.DefaultCompression
+		if best {
+			level = gzip.BestCompression
+		}
+		gw, err := gzip.NewWriterLevel(&buf, level)
+		if err != nil {
+			return nil, err
+		}
+		w = gw
+	default:
+		return content, nil
+	}
+
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// precompress return all compressed variants of content (keyed by content coding) that are actually
+// smaller than content itself, nil if content is not compressible
+func precompress(content []byte, mimeType string) map[string][]byte {
+	if len(content) < minCompressSize || !compressible(mimeType) {
+		return nil
+	}
+
+	encoded := make(map[string][]byte)
+
+	for _, enc := range contentCodings {
+		c, err := compress(enc, content, true)
+		if err != nil {
+			log.Warn().Msg(err.Error())
+			continue
+		}
+
+		if len(c) < len(content) {
+			encoded[enc] = c
+		}
+	}
+
+	if len(encoded) == 0 {
+		return nil
+	}
+
+	return encoded
+}
+
+// negotiateEncoding choose the content coding from available that is preferred by the client
+// based on the Accept-Encoding header, return "" for no compression ('identity')
+// see https://tools.ietf.org/html/rfc7231#section-5.3.4
+func negotiateEncoding(acceptEncoding string, available []string) string {
+	q := make(map[string]float64)
+
+	for _, e := range strings.Split(acceptEncoding, ",") {
+		p := strings.Split(e, ";")
+
+		coding := strings.ToLower(strings.TrimSpace(p[0]))
+		if coding == "" {
+			continue
+		}
+
+		weight := 1.0
+		for _, param := range p[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.ToLower(kv[0]) == "q" {
+				if f, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					weight = f
+				}
+			}
+		}
+
+		q[coding] = weight
+	}
+
+	best, bestQ := "", 0.0
+
+	// contentCodings is in order of preference, so on equal weight the first one wins
+	for _, enc := range contentCodings {
+		found := false
+		for _, a := range available {
+			if a == enc {
+				found = true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		w, ok := q[enc]
+		if !ok {
+			w, ok = q["*"]
+		}
+
+		if ok && w > bestQ {
+			best, bestQ = enc, w
+		}
+	}
+
+	return best
+}
+
+// encodedETag return the entity tag of the variant of content compressed with enc,
+// since every representation needs an entity tag of its own
+func encodedETag(etag string, enc string) string {
+	return strings.TrimSuffix(etag, `"`) + "-" + enc + `"`
+}
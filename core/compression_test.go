@@ -0,0 +1,45 @@
+package core
+
+import "testing"
+
+func TestNegotiateEncoding(t *testing.T) {
+	all := []string{"br", "gzip"}
+	gzipOnly := []string{"gzip"}
+
+	tests := []struct {
+		acceptEncoding string
+		available      []string
+		want           string
+	}{
+		{"", all, ""},
+		{"identity", all, ""},
+		{"identity;q=1, *;q=0", all, ""},
+		{"gzip", all, "gzip"},
+		{"br", all, "br"},
+		{"gzip, br", all, "br"},
+		{"GZIP, deflate", all, "gzip"},
+		{"gzip;q=1, br;q=0.5", all, "gzip"},
+		{"gzip;q=0.5, br;q=1", all, "br"},
+		{"gzip; q=0.8, br", all, "br"},
+		{"br;q=0", all, ""},
+		{"br;q=0, gzip", all, "gzip"},
+		{"gzip;q=0, br;q=0", all, ""},
+		{"*", all, "br"},
+		{"*;q=0", all, ""},
+		{"*;q=0, gzip", all, "gzip"},
+		{"*;q=0.5, gzip", all, "gzip"},
+		{"*, br;q=0", all, "gzip"},
+		{"deflate", all, ""},
+		{"gzip;q=invalid", all, "gzip"},
+		{"gzip, br", gzipOnly, "gzip"},
+		{"br", gzipOnly, ""},
+		{"*", gzipOnly, "gzip"},
+		{"gzip, br", nil, ""},
+	}
+
+	for _, test := range tests {
+		if got := negotiateEncoding(test.acceptEncoding, test.available); got != test.want {
+			t.Errorf("negotiateEncoding(%q, %q) = %q, want %q", test.acceptEncoding, test.available, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/THREATINT/go-crypto"
+)
+
+// checkPreconditions evaluate the conditional request headers of r in the order defined by
+// RFC 7232 (see https://tools.ietf.org/html/rfc7232#section-6), return the HTTP status code to respond
+// with instead of the content (304 or 412), 0 if the content is to be sent.
+// A zero lastModified means that there is no modification date for the content.
+func checkPreconditions(r *http.Request, etag string, lastModified time.Time) int {
+	lastModified = lastModified.Truncate(time.Second)
+
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !etagMatch(im, etag, false) {
+			return 412
+		}
+	} else if ius, ok := parseHTTPDate(r.Header.Get("If-Unmodified-Since")); ok && !lastModified.IsZero() {
+		if lastModified.After(ius) {
+			return 412
+		}
+	}
+
+	method := strings.ToUpper(r.Method)
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		// If-None-Match takes precedence over If-Modified-Since
+		if etagMatch(inm, etag, true) {
+			if method == "GET" || method == "HEAD" {
+				return 304
+			}
+			return 412
+		}
+	} else if ims, ok := parseHTTPDate(r.Header.Get("If-Modified-Since")); ok && !lastModified.IsZero() {
+		if (method == "GET" || method == "HEAD") && !lastModified.After(ims) {
+			return 304
+		}
+	}
+
+	return 0
+}
+
+// newETag return a strong entity tag for content
+func newETag(content []byte) string {
+	return `"` + crypto.RIPEMD160(string(content)) + `"`
+}
+
+// etagMatch return if etag matches any of the entity tags listed in the value of an If-Match
+// (strong comparison) or If-None-Match (weak comparison) header
+// see https://tools.ietf.org/html/rfc7232#section-2.3.2
+func etagMatch(header string, etag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		// matches any current representation
+		return etag != ""
+	}
+
+	for _, t := range parseETags(header) {
+		if weak {
+			if strings.TrimPrefix(t, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if !strings.HasPrefix(t, "W/") && !strings.HasPrefix(etag, "W/") && t == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseETags parse a comma separated list of entity tags, e.g. `"xyzzy", W/"r2d2xxxx"`,
+// invalid elements are skipped
+func parseETags(s string) []string {
+	var etags []string
+
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return etags
+		}
+
+		prefix := ""
+		if strings.HasPrefix(s, "W/") {
+			prefix = "W/"
+			s = s[2:]
+		}
+
+		if !strings.HasPrefix(s, `"`) {
+			// not an entity tag -> skip to the next element
+			i := strings.Index(s, ",")
+			if i < 0 {
+				return etags
+			}
+			s = s[i+1:]
+			continue
+		}
+
+		i := strings.Index(s[1:], `"`)
+		if i < 0 {
+			// missing closing quote
+			return etags
+		}
+
+		etags = append(etags, prefix+s[:i+2])
+		s = s[i+2:]
+	}
+}
+
+// setLastModified set Last-Modified header, unless there is no modification date (= zero time)
+func setLastModified(w http.ResponseWriter, lastModified time.Time) {
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// parseHTTPDate parse an HTTP-date (see https://tools.ietf.org/html/rfc7231#section-7.1.1.1)
+func parseHTTPDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+
+	t, err := http.ParseTime(s)
+	if err != nil {
+		// invalid dates are to be ignored
+		return time.Time{}, false
+	}
+
+	return t, true
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseETags(t *testing.T) {
+	tests := []struct {
+		header string
+		etags  []string
+	}{
+		{"", nil},
+		{`"xyzzy"`, []string{`"xyzzy"`}},
+		{`"xyzzy", W/"r2d2xxxx", "c3piozzzz"`, []string{`"xyzzy"`, `W/"r2d2xxxx"`, `"c3piozzzz"`}},
+		{`W/"a",W/"b"`, []string{`W/"a"`, `W/"b"`}},
+		{`W/"a", junk, "b"`, []string{`W/"a"`, `"b"`}},
+		{`"a,b", "c"`, []string{`"a,b"`, `"c"`}},
+		{`""`, []string{`""`}},
+		{`junk`, nil},
+		{`"a", "b`, []string{`"a"`}},
+	}
+
+	for _, test := range tests {
+		if etags := parseETags(test.header); !reflect.DeepEqual(etags, test.etags) {
+			t.Errorf("parseETags(%q) = %q, want %q", test.header, etags, test.etags)
+		}
+	}
+}
+
+func TestETagMatch(t *testing.T) {
+	tests := []struct {
+		header string
+		etag   string
+		weak   bool
+		want   bool
+	}{
+		{"*", `"a"`, false, true},
+		{"*", `"a"`, true, true},
+		{" * ", `W/"a"`, false, true},
+		{"*", "", false, false},
+		{"*", "", true, false},
+		{`"a"`, `"a"`, false, true},
+		{`"b", "a"`, `"a"`, false, true},
+		{`"b"`, `"a"`, false, false},
+		{`W/"a"`, `"a"`, false, false},
+		{`"a"`, `W/"a"`, false, false},
+		{`W/"a"`, `W/"a"`, false, false},
+		{`W/"a"`, `"a"`, true, true},
+		{`"a"`, `W/"a"`, true, true},
+		{`W/"b", W/"a"`, `W/"a"`, true, true},
+		{`W/"b"`, `"a"`, true, false},
+		{`junk`, `"a"`, true, false},
+	}
+
+	for _, test := range tests {
+		if got := etagMatch(test.header, test.etag, test.weak); got != test.want {
+			t.Errorf("etagMatch(%q, %q, %v) = %v, want %v", test.header, test.etag, test.weak, got, test.want)
+		}
+	}
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	lastModified := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	etag := `"abc"`
+
+	const (
+		before = "Sun, 18 Oct 2026 09:00:00 GMT"
+		same   = "Sun, 18 Oct 2026 10:00:00 GMT"
+		after  = "Sun, 18 Oct 2026 11:00:00 GMT"
+	)
+
+	tests := []struct {
+		name         string
+		method       string
+		headers      map[string]string
+		lastModified time.Time
+		want         int
+	}{
+		{"no preconditions", "GET", nil, lastModified, 0},
+
+		{"If-Match matches", "GET", map[string]string{"If-Match": `"abc"`}, lastModified, 0},
+		{"If-Match list", "PUT", map[string]string{"If-Match": `"x", "abc"`}, lastModified, 0},
+		{"If-Match *", "PUT", map[string]string{"If-Match": "*"}, lastModified, 0},
+		{"If-Match mismatch", "GET", map[string]string{"If-Match": `"x"`}, lastModified, 412},
+		{"If-Match weak", "GET", map[string]string{"If-Match": `W/"abc"`}, lastModified, 412},
+
+		{"If-Unmodified-Since same", "PUT", map[string]string{"If-Unmodified-Since": same}, lastModified, 0},
+		{"If-Unmodified-Since after", "PUT", map[string]string{"If-Unmodified-Since": after}, lastModified, 0},
+		{"If-Unmodified-Since before", "PUT", map[string]string{"If-Unmodified-Since": before}, lastModified, 412},
+		{"If-Unmodified-Since invalid", "PUT", map[string]string{"If-Unmodified-Since": "yesterday"}, lastModified, 0},
+		{"If-Unmodified-Since without date", "PUT", map[string]string{"If-Unmodified-Since": before}, time.Time{}, 0},
+		{"If-Match over If-Unmodified-Since", "PUT",
+			map[string]string{"If-Match": `"abc"`, "If-Unmodified-Since": before}, lastModified, 0},
+
+		{"If-None-Match GET", "GET", map[string]string{"If-None-Match": `"abc"`}, lastModified, 304},
+		{"If-None-Match HEAD", "HEAD", map[string]string{"If-None-Match": `"abc"`}, lastModified, 304},
+		{"If-None-Match POST", "POST", map[string]string{"If-None-Match": `"abc"`}, lastModified, 412},
+		{"If-None-Match weak", "GET", map[string]string{"If-None-Match": `W/"abc"`}, lastModified, 304},
+		{"If-None-Match list", "GET", map[string]string{"If-None-Match": `"x", W/"abc"`}, lastModified, 304},
+		{"If-None-Match *", "GET", map[string]string{"If-None-Match": "*"}, lastModified, 304},
+		{"If-None-Match * POST", "POST", map[string]string{"If-None-Match": "*"}, lastModified, 412},
+		{"If-None-Match mismatch", "GET", map[string]string{"If-None-Match": `"x"`}, lastModified, 0},
+
+		{"If-Modified-Since same", "GET", map[string]string{"If-Modified-Since": same}, lastModified, 304},
+		{"If-Modified-Since after", "HEAD", map[string]string{"If-Modified-Since": after}, lastModified, 304},
+		{"If-Modified-Since before", "GET", map[string]string{"If-Modified-Since": before}, lastModified, 0},
+		{"If-Modified-Since POST", "POST", map[string]string{"If-Modified-Since": same}, lastModified, 0},
+		{"If-Modified-Since invalid", "GET", map[string]string{"If-Modified-Since": "yesterday"}, lastModified, 0},
+		{"If-Modified-Since without date", "GET", map[string]string{"If-Modified-Since": after}, time.Time{}, 0},
+		{"If-Modified-Since sub-second", "GET", map[string]string{"If-Modified-Since": same},
+			lastModified.Add(500 * time.Millisecond), 304},
+
+		{"If-None-Match mismatch over If-Modified-Since", "GET",
+			map[string]string{"If-None-Match": `"x"`, "If-Modified-Since": after}, lastModified, 0},
+		{"If-None-Match match over If-Modified-Since", "GET",
+			map[string]string{"If-None-Match": `"abc"`, "If-Modified-Since": before}, lastModified, 304},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, "/", nil)
+		for k, v := range test.headers {
+			r.Header.Set(k, v)
+		}
+
+		if got := checkPreconditions(r, etag, test.lastModified); got != test.want {
+			t.Errorf("%s: checkPreconditions = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
@@ -3,8 +3,10 @@ package core
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
+	TIhttp "github.com/THREATINT/go-http"
 	"github.com/blevesearch/bleve"
 )
 
@@ -16,6 +18,13 @@ type Context struct {
 	PublicFiles   map[string]*PublicFile
 	AllNodes      []*Node
 	FulltextIndex bleve.Index
+	Relations     *Relations
+	Preview       bool
+	Draft         bool
+	CSPNonce      string
+	Site          *Site
+	Form          *FormState
+	CSRFToken     string
 }
 
 // FindByPath find node by path
@@ -28,37 +37,127 @@ func (context *Context) RootNodes() []*Node {
 	return RootNodes(context.AllNodes)
 }
 
-// Search search all nodes
+// Related return at most n (up to 20) enabled nodes in the language of node that are most similar to it, none if
+// search is disabled
+func (context *Context) Related(node *Node, n int) []*Node {
+	return context.Relations.Related(node, n)
+}
+
+// Search search nodes in the language of the current node, or the language accepted by the visitor if the
+// node has none (see SearchLanguage to search in all languages)
 func (context *Context) Search(term string, maxresults int) []SearchResult {
-	term = strings.Replace(term, "*", " ", -1)
-	term = strings.Replace(term, "?", " ", -1)
-	term = strings.TrimSpace(term)
+	return context.SearchLanguage(term, context.searchLanguage(), maxresults)
+}
 
-	var r []SearchResult
+// SearchLanguage search nodes in language, in all languages if language is ""
+func (context *Context) SearchLanguage(term string, language string, maxresults int) []SearchResult {
+	if maxresults <= 0 {
+		return nil
+	}
 
-	q := bleve.NewQueryStringQuery(term)
+	results := context.SearchQuery(term, "language", language, "limit", maxresults)
 
-	req := bleve.NewSearchRequest(q)
-	req.Highlight = bleve.NewHighlightWithStyle("html")
-	req.Fields = []string{""}
+	return results.Results
+}
 
-	resp, err := context.FulltextIndex.Search(req)
-	if err != nil {
-		return r
+// SearchQuery search nodes, options are pairs of name and value:
+// "mode" (match, phrase, fuzzy, prefix or query, see SearchMatch etc.), "language" ("" for all languages, defaults to
+// the language Search would use), "offset", "limit" (defaults to 10), "facet" (language, template or a taxonomy
+// property, see Site.SearchFacets; may be given more than once), "facet-size" (defaults to 10) and
+// "filter" (e.g. "template=blog"; may be given more than once).
+// Errors are logged, the result is empty then.
+func (context *Context) SearchQuery(term string, options ...interface{}) *SearchResults {
+	r := searchRequest{Term: term, Language: context.searchLanguage(), Limit: 10}
+
+	if context.FulltextIndex == nil {
+		// search disabled
+		return &SearchResults{Limit: r.Limit}
 	}
 
-	for i, m := range resp.Hits {
-		if i == maxresults {
-			break
+	if len(options)%2 != 0 {
+		log.Warn().Msg(fmt.Sprintf("%s: SearchQuery: options must be pairs of name and value", context.Node.Path()))
+		return &SearchResults{Limit: r.Limit}
+	}
+
+	for i := 0; i < len(options); i += 2 {
+		name := strings.ToLower(fmt.Sprint(options[i]))
+		value := strings.TrimSpace(fmt.Sprint(options[i+1]))
+
+		var err error
+		switch name {
+		case "mode":
+			r.Mode = value
+		case "language", "lang":
+			r.Language = value
+		case "offset":
+			r.Offset, err = strconv.Atoi(value)
+		case "limit":
+			r.Limit, err = strconv.Atoi(value)
+		case "facet":
+			for _, f := range strings.Split(value, ",") {
+				if f = strings.TrimSpace(f); f != "" {
+					r.Facets = append(r.Facets, f)
+				}
+			}
+		case "facet-size":
+			r.FacetSize, err = strconv.Atoi(value)
+		case "filter":
+			kv := strings.SplitN(value, "=", 2)
+			if len(kv) != 2 {
+				err = fmt.Errorf("invalid filter '%s'", value)
+				break
+			}
+			if r.Filters == nil {
+				r.Filters = make(map[string]string)
+			}
+			r.Filters[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		default:
+			err = fmt.Errorf("unknown option '%s'", name)
 		}
 
-		c := ""
-		for _, f := range m.Fragments {
-			c = fmt.Sprintf("%s<br/>%s", c, f[0])
+		if err != nil {
+			log.Warn().Msg(fmt.Sprintf("%s: SearchQuery: %s", context.Node.Path(), err.Error()))
+			return &SearchResults{Limit: r.Limit}
 		}
+	}
 
-		r = append(r, SearchResult{Index: i + 1, URL: m.ID, Score: fmt.Sprintf("%.4f", m.Score), Content: c})
+	results, err := search(context.FulltextIndex, context.AllNodes, context.Site, r)
+	if err != nil {
+		log.Warn().Msg(fmt.Sprintf("%s: SearchQuery: %s", context.Node.Path(), err.Error()))
+		return &SearchResults{Offset: r.Offset, Limit: r.Limit}
+	}
+
+	return results
+}
+
+// searchLanguage return language to search in by default: the language of the current node, otherwise the first
+// language accepted by the visitor that there are nodes in, "" (= all) if there is none
+func (context *Context) searchLanguage() string {
+	if context.Node != nil && context.Node.Language() != "" {
+		return context.Node.Language()
+	}
+
+	return acceptedLanguage(context.HTTPRequest, context.AllNodes)
+}
+
+// acceptedLanguage return the first language accepted by the client of r that there are nodes in, "" if there is none
+func acceptedLanguage(r *http.Request, nodes []*Node) string {
+	if r == nil {
+		return ""
+	}
+
+	available := make(map[string]bool)
+	for _, l := range Languages(nodes) {
+		available[l] = true
+	}
+
+	for _, l := range TIhttp.ParseAcceptLanguage(r.Header.Get("Accept-Language")) {
+		for _, c := range []string{strings.ToLower(l.Lang), strings.ToLower(strings.Split(l.Lang, "-")[0])} {
+			if available[c] {
+				return c
+			}
+		}
 	}
 
-	return r
+	return ""
 }
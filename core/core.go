@@ -10,10 +10,12 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
 
-	"github.com/THREATINT/go-crypto"
 	TIhttp "github.com/THREATINT/go-http"
 	"github.com/blevesearch/bleve"
 	"github.com/microcosm-cc/bluemonday"
@@ -31,28 +33,53 @@ import (
 var log zerolog.Logger
 
 // NewCore Initialiser for new onacms core engine
-func NewCore(fs *afero.Fs, logger zerolog.Logger) *Core {
+func NewCore(fs *afero.Fs, logger zerolog.Logger, indexDir string) *Core {
 
 	log = logger
 
+	start := time.Now()
+
 	c := new(Core)
 
 	c.fs = fs
 
-	indexMapping := bleve.NewIndexMapping()
-	ftindex, err := bleve.NewMemOnly(indexMapping)
-	if err != nil {
-		log.Error().Msg(err.Error())
-	} else {
-		c.ftindex = ftindex
+	c.Site = &Site{}
+
+	log.Info().Msg("reading site configuration...")
+	c.populateSite("site.xml")
+	if c.Site.Title() != "" {
+		log.Info().Msg(c.Site.Title())
 	}
 
 	c.HTTPHeaders = &HTTPHeaders{}
 
+	c.Caching = &Caching{}
+
+	c.Security = &Security{}
+
+	// rendered pages contain this placeholder instead of the per-request CSP nonce, so that they can still be cached
+	var err error
+	c.noncePlaceholder, err = newNonce()
+	if err != nil {
+		// an empty placeholder would put the nonce between every byte of every page
+		log.Fatal().Msg(fmt.Sprintf("CSP nonce placeholder: %s", err.Error()))
+	}
+
+	// random key of CSRF tokens unless set with SetFormSecret, forms rendered before a restart cannot be submitted
+	// afterwards
+	csrfKey, err := newNonce()
+	if err != nil {
+		// CSRF tokens signed with an empty key could be forged
+		log.Fatal().Msg(fmt.Sprintf("CSRF key: %s", err.Error()))
+	}
+	c.csrfKey = []byte(csrfKey)
+
 	c.PublicFiles = make(map[string]*PublicFile)
 
 	c.Templates = make(map[string]*Template)
 
+	c.pages = make(map[string]*renderedPage)
+
 	c.minifier = minify.New()
 	c.minifier.AddFunc("text/plain", TextMinify.Minify)
 	c.minifier.AddFunc("text/css", css.Minify)
@@ -66,6 +93,14 @@ func NewCore(fs *afero.Fs, logger zerolog.Logger) *Core {
 	c.populateHeaders("http-headers.xml")
 	log.Info().Msg(fmt.Sprintf("%d HTTP header(s)", len(c.HTTPHeaders.URI)))
 
+	log.Info().Msg("reading caching policy...")
+	c.populateCaching("caching.xml")
+	log.Info().Msg(fmt.Sprintf("%d caching rule(s) for public files", len(c.Caching.PublicFiles)))
+
+	log.Info().Msg("reading security headers...")
+	c.populateSecurity("security-headers.xml")
+	log.Info().Msg(fmt.Sprintf("%d security header(s)", len(c.Security.Headers(""))))
+
 	log.Info().Msg("reading public files...")
 	c.populatePublicFiles("public")
 	log.Info().Msg(fmt.Sprintf("%d public file(s)", len(c.PublicFiles)))
@@ -78,10 +113,37 @@ func NewCore(fs *afero.Fs, logger zerolog.Logger) *Core {
 	c.populateNodes("nodes")
 	log.Info().Msg(fmt.Sprintf("%d node(s)", len(c.Nodes)))
 
-	log.Info().Msg("building search index...")
-	c.populateFTIndex()
-	dc, err := c.ftindex.DocCount()
-	log.Info().Msg(fmt.Sprintf("%d node(s) in index", dc))
+	// nodes published or expiring from now on are handled by schedulePublishing
+	now := time.Now()
+
+	// search can be disabled in site.xml
+	if c.Site.Search() {
+		log.Info().Msg("building search index...")
+		ftindex, err := openFTIndex(indexDir, newIndexMapping())
+		if err != nil {
+			log.Error().Msg(fmt.Sprintf("search index: %s", err.Error()))
+		} else {
+			c.ftindex = ftindex
+
+			c.populateFTIndex()
+			dc, _ := c.ftindex.DocCount()
+			log.Info().Msg(fmt.Sprintf("%d node(s) in index", dc))
+			c.Relations = NewRelations(c.ftindex, c.Nodes, c.Site)
+		}
+
+		c.suggestions = newSuggestIndex(c.Nodes, c.Site)
+		log.Info().Msg(fmt.Sprintf("%d suggestion key(s)", len(c.suggestions.keys)))
+	}
+
+	c.schedulePublishing(now)
+
+	c.version = c.Site.Version()
+	if c.version == "" {
+		c.version = c.contentHash()
+	}
+	log.Info().Msg(fmt.Sprintf("site version %s", c.version))
+
+	c.observeLoad(start)
 
 	return c
 }
@@ -89,28 +151,50 @@ func NewCore(fs *afero.Fs, logger zerolog.Logger) *Core {
 // Core struct for onacms core engine
 type Core struct {
 	Nodes       []*Node
+	Site        *Site
 	PublicFiles map[string]*PublicFile
 	Templates   map[string]*Template
 	HTTPHeaders *HTTPHeaders
+	Caching     *Caching
+	Security    *Security
+	Preview     *Preview
+	Forms       *Forms
+	Relations   *Relations
 	fs          *afero.Fs
 	minifier    *minify.M
 	ftindex     bleve.Index
+	suggestions *suggestIndex
+	pages       map[string]*renderedPage
+	pagesMutex  sync.RWMutex
+
+	noncePlaceholder string
+	version          string
+	csrfKey          []byte
 }
 
 // HTTP ...
 func (core *Core) HTTP(w http.ResponseWriter, r *http.Request) {
 
-	// we do not understand HTTP Range requests -> ignore
+	// HTTP Range requests are supported for public files only, nodes ignore them
 	// see https://tools.ietf.org/html/rfc7233#section-1.1
-	// - no action needed -
 
-	// Allow only HTTP GET and HEAD
-	if strings.ToUpper(r.Method) != "GET" && strings.ToUpper(r.Method) != "HEAD" {
-		// neither HTTP GET nor HEAD? -> return 405 ("Method Not Allowed")
+	info := requestInfo(r)
+
+	// Allow only HTTP GET and HEAD, POST for forms
+	if strings.ToUpper(r.Method) != "GET" && strings.ToUpper(r.Method) != "HEAD" && strings.ToUpper(r.Method) != "POST" {
+		// neither HTTP GET nor HEAD nor POST? -> return 405 ("Method Not Allowed")
 		w.WriteHeader(405)
 		return
 	}
 
+	// security headers (see security-headers.xml) are sent with every response
+	nonce, security, err := core.setSecurityHeaders(w)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		http.Error(w, "", 500)
+		return
+	}
+
 	u, err := url.Parse(strings.ToLower(r.URL.String()))
 	if err != nil {
 		// error parsing the URL? -> HTTP 400 ("Bad Request")
@@ -131,6 +215,7 @@ func (core *Core) HTTP(w http.ResponseWriter, r *http.Request) {
 
 	if newurlpath != origurlpath {
 		log.Warn().Msg(fmt.Sprintf("Possible XSS: '%s', sansitised to '%s'", origurlpath, newurlpath))
+		info.Resolution = ResolutionRedirect
 		http.Redirect(w, r, string(newurlpath), 303)
 		return
 	}
@@ -140,6 +225,7 @@ func (core *Core) HTTP(w http.ResponseWriter, r *http.Request) {
 	// to avoid "duplicate content" problem with search engines
 	urlpath := strings.TrimSuffix(u.Path, "/")
 	if u.Path != urlpath && urlpath != "" {
+		info.Resolution = ResolutionRedirect
 		http.Redirect(w, r, string(urlpath), 303)
 		return
 	}
@@ -147,35 +233,50 @@ func (core *Core) HTTP(w http.ResponseWriter, r *http.Request) {
 	// remove leading slash ("/")
 	urlpath = strings.TrimPrefix(urlpath, "/")
 
+	if strings.ToUpper(r.Method) == "POST" {
+		core.submitForm(w, r, urlpath, nonce)
+		return
+	}
+
 	var content []byte
+	var mimeType string
+	var etag string
+	var lastModified time.Time
+	var encoded map[string][]byte
+	var compressOnTheFly bool
+	var cacheControl string
+	var draft bool
 
 	// we start by searching the static content:
 	f := core.PublicFiles[urlpath]
 	if f != nil {
-		etag := crypto.RIPEMD160(string(f.Content[:]))
+		info.Resolution = ResolutionPublicFile
+		info.Target = "/" + urlpath
 
-		// send ETag, no matter if 200 or 304 (see https://tools.ietf.org/html/rfc7232#section-4.1)
-		w.Header().Set("Etag", etag)
-
-		inm := r.Header.Get("If-None-Match")
-		if inm != "" && strings.Contains(inm, etag) {
-			// no change here
-			w.WriteHeader(304)
-			return
-		}
-
-		w.Header().Set("Content-Type", f.MimeType)
+		w.Header().Set("Accept-Ranges", "bytes")
 
 		content = f.Content
+		mimeType = f.MimeType
+		etag = f.ETag
+		lastModified = f.ModTime
+		encoded = f.Encoded
+		cacheControl = core.Caching.PublicFile(f.MimeType)
 	} else {
 		// we have not found matching static content, so we start searching our nodes list:
+		preview := core.Preview.Authorised(w, r)
 		node := FindNode(urlpath, core.Nodes)
+		if node == nil && preview {
+			// draft preview mode: disabled and future-dated nodes get rendered as well
+			node = FindDraftNode(urlpath, core.Nodes)
+		}
 		if node == nil {
 			node = FindApplicationEndpointNode(urlpath, core.Nodes)
 			if node == nil {
 				node = FindFallbackNode(urlpath, core.Nodes)
 
 				if node != nil {
+					info.Resolution = ResolutionFallbackRedirect
+					info.Target = string(node.Path())
 					http.Redirect(w, r, string(node.Path()), 303)
 					return
 				}
@@ -188,6 +289,8 @@ func (core *Core) HTTP(w http.ResponseWriter, r *http.Request) {
 				for _, l := range acceptLang {
 					for _, n := range RootNodes(core.Nodes) {
 						if n.Language() == l.Lang && n.Enabled() {
+							info.Resolution = ResolutionLanguageRedirect
+							info.Target = string(n.Path())
 							http.Redirect(w, r, string(n.Path()), 303)
 							return
 						}
@@ -201,16 +304,30 @@ func (core *Core) HTTP(w http.ResponseWriter, r *http.Request) {
 				for _, l := range acceptLang {
 					for _, n := range RootNodes(core.Nodes) {
 						if n.Language() == strings.Split(l.Lang, "-")[0] && n.Enabled() {
+							info.Resolution = ResolutionLanguageRedirect
+							info.Target = string(n.Path())
 							http.Redirect(w, r, string(n.Path()), 303)
 							return
 						}
 					}
 				}
 
+				// no luck with the languages accepted by the client, so we try the default language of the site
+				for _, n := range RootNodes(core.Nodes) {
+					if n.Language() == core.Site.DefaultLanguage() && n.Enabled() {
+						info.Resolution = ResolutionLanguageRedirect
+						info.Target = string(n.Path())
+						http.Redirect(w, r, string(n.Path()), 303)
+						return
+					}
+				}
+
 				// we tried almost everything ... last resort:
 				// we redirect to the first node that is available (aka: enabled)
 				for _, n := range RootNodes(core.Nodes) {
 					if n.Enabled() {
+						info.Resolution = ResolutionLanguageRedirect
+						info.Target = string(n.Path())
 						http.Redirect(w, r, string(n.Path()), 303)
 						return
 					}
@@ -219,101 +336,200 @@ func (core *Core) HTTP(w http.ResponseWriter, r *http.Request) {
 				// you guessed it: we give up!
 				// Nothing to be found here!
 				// We are done.
+				info.Resolution = ResolutionNotFound
 				http.Error(w, "not found", 404)
 				return
 			}
 		}
 
+		info.Resolution = ResolutionNode
+		if node.ApplicationEndpoint() {
+			info.Resolution = ResolutionEndpoint
+		}
+		info.Target = string(node.Path())
+
 		if node.RedirectTo() != "" {
+			info.Resolution = ResolutionRedirect
 			http.Redirect(w, r, strings.TrimSpace(string(node.RedirectTo())), 303)
 			return
 		}
 
-		context := Context{
-			HTTPRequest:   r,
-			Node:          node,
-			Content:       node.Render(),
-			AllNodes:      core.Nodes,
-			PublicFiles:   core.PublicFiles,
-			FulltextIndex: core.ftindex,
+		// forms carry a CSRF token bound to a cookie
+		var csrf string
+		var form *FormState
+		if node.Form() != nil {
+			csrf, err = core.csrfToken(w, r, node)
+			if err != nil {
+				log.Error().Msg(err.Error())
+				http.Error(w, "", 500)
+				return
+			}
+			form = &FormState{}
 		}
 
-		var lr bytes.Buffer
-		lr.WriteString(r.RemoteAddr)
-		lr.WriteString(" ")
-		lr.WriteString(r.Method)
-		lr.WriteString(" ")
-		lr.WriteString(r.Host)
-		lr.WriteString(r.RequestURI)
-		lr.WriteString(" ")
-		lr.WriteString(r.URL.Port())
-		lr.WriteString(" - ")
+		// pages that do not depend on the request are rendered and compressed only once
+		// previews carry the preview cookie and may differ from what visitors get, so they are never cached
+		cacheable := core.cacheable(node) && !preview
 
-		t := core.Templates[node.Template()]
-		if t == nil {
-			log.Error().Msg(lr.String())
-			return
-		}
+		var page *renderedPage
+		info.Cache = CacheBypass
+		if cacheable {
+			page = core.cachedPage(node)
 
-		for {
-			var buf bytes.Buffer
-			gt := template.New(t.Name())
-			gt, err = gt.Parse(t.Content())
+			info.Cache = CacheHit
+			if page == nil {
+				info.Cache = CacheMiss
+			}
+		}
 
-			if err != nil {
-				log.Error().Msg(fmt.Sprintf("%s: %s", lr.String(), err.Error()))
-				w.WriteHeader(500)
-				return
+		if page == nil {
+			context := Context{
+				HTTPRequest:   r,
+				Node:          node,
+				Content:       node.Render(),
+				AllNodes:      core.Nodes,
+				PublicFiles:   core.PublicFiles,
+				FulltextIndex: core.ftindex,
+				Relations:     core.Relations,
+				Preview:       preview,
+				Draft:         !node.Enabled(),
+				CSPNonce:      core.noncePlaceholder,
+				Site:          core.Site,
+				Form:          form,
+				CSRFToken:     csrf,
 			}
 
-			err = gt.Execute(&buf, &context)
+			var lr bytes.Buffer
+			lr.WriteString(r.RemoteAddr)
+			lr.WriteString(" ")
+			lr.WriteString(r.Method)
+			lr.WriteString(" ")
+			lr.WriteString(r.Host)
+			lr.WriteString(r.RequestURI)
+			lr.WriteString(" ")
+			lr.WriteString(r.URL.Port())
+			lr.WriteString(" - ")
+
+			page, err = core.render(&context)
 			if err != nil {
+				renderErrors.Inc()
 				log.Error().Msg(fmt.Sprintf("%s: %s", lr.String(), err.Error()))
 				w.WriteHeader(500)
 				return
 			}
 
-			context.Content = buf.String()
-
-			if t.Parent() == "" {
-				break
+			if cacheable {
+				if core.Site.Compression() && !bytes.Contains(page.Content, []byte(core.noncePlaceholder)) {
+					page.Encoded = precompress(page.Content, page.MimeType)
+				}
+				core.cachePage(node, page)
 			}
-
-			t = core.Templates[t.Parent()]
 		}
 
-		// Minify the content
-		page, err := core.minifier.String(t.MimeType(), string(context.Content))
-		if err != nil {
-			// If minifying goes wrong for any reason, we leave the original content untouched and continue
-			page = string(context.Content)
-			log.Warn().Msg(err.Error())
+		draft = !node.Enabled() || preview
+		cacheControl = core.CacheControl(node)
+		if node.Form() != nil {
+			// the CSRF token must not end up in shared caches
+			cacheControl = "private, no-cache"
 		}
 
-		etag := crypto.RIPEMD160(page)
+		content = page.Content
+		mimeType = page.MimeType + "; charset=UTF-8"
+		etag = page.ETag
+		lastModified = page.LastModified
+		encoded = page.Encoded
+
+		compressOnTheFly = !cacheable && len(content) >= minCompressSize && compressible(mimeType)
+
+		if bytes.Contains(content, []byte(core.noncePlaceholder)) {
+			// the page carries a nonce that changes with every request, so it can neither be precompressed
+			// nor validated (a 304 would pair the page cached by the client with a new nonce)
+			content = bytes.Replace(content, []byte(core.noncePlaceholder), []byte(nonce), -1)
+			etag = ""
+			lastModified = time.Time{}
+			encoded = nil
+			compressOnTheFly = len(content) >= minCompressSize && compressible(mimeType)
+		}
+	}
 
-		// send ETag, no matter if 200 or 304 (see https://tools.ietf.org/html/rfc7232#section-4.1)
-		w.Header().Set("Etag", etag)
+	// content negotiation: send a compressed variant if the client accepts one
+	// see https://tools.ietf.org/html/rfc7231#section-5.3.4
+	if core.Site.Compression() && (len(encoded) > 0 || compressOnTheFly) {
+		w.Header().Add("Vary", "Accept-Encoding")
 
-		// Etag in request matches our Etag? -> content has not chanced
-		inm := r.Header.Get("If-None-Match")
-		if inm != "" && strings.Contains(inm, etag) {
-			w.WriteHeader(304)
-			return
+		available := contentCodings
+		if !compressOnTheFly {
+			available = nil
+			for enc := range encoded {
+				available = append(available, enc)
+			}
 		}
 
-		w.Header().Set("Content-Type", t.MimeType()+"; charset=UTF-8")
+		if enc := negotiateEncoding(r.Header.Get("Accept-Encoding"), available); enc != "" {
+			c, ok := encoded[enc]
+			if !ok {
+				c, err = compress(enc, content, false)
+			}
 
-		if strings.ToUpper(r.Method) != "HEAD" {
-			// don't send body if HTTP Method is HEAD
-			content = []byte(page)
+			if err != nil {
+				log.Warn().Msg(err.Error())
+			} else {
+				content = c
+				etag = encodedETag(etag, enc)
+				w.Header().Set("Content-Encoding", enc)
+			}
 		}
 	}
 
+	// send ETag, no matter if 200 or 304 (see https://tools.ietf.org/html/rfc7232#section-4.1)
+	if etag != "" {
+		w.Header().Set("Etag", etag)
+	}
+	setLastModified(w, lastModified)
+
 	// set HTTP headers based on URI
+	explicit := make(map[string]bool)
 	for _, h := range core.HTTPHeaders.Match(urlpath) {
 		r := strings.SplitN(h, ":", 2)
-		w.Header().Add(strings.TrimSpace(r[0]), strings.TrimSpace(r[1]))
+		k := http.CanonicalHeaderKey(strings.TrimSpace(r[0]))
+
+		if _, ok := security[k]; ok && !explicit[k] {
+			// headers set explicitly in http-headers.xml replace security headers
+			w.Header().Del(k)
+		}
+		explicit[k] = true
+
+		w.Header().Add(k, strings.TrimSpace(r[1]))
+	}
+
+	if draft {
+		// drafts and previews must neither be cached nor indexed by search engines
+		w.Header().Set("Cache-Control", "private, no-store")
+		w.Header().Set("X-Robots-Tag", "noindex")
+	} else if cacheControl != "" && w.Header().Get("Cache-Control") == "" {
+		// caching policy, unless Cache-Control has been set explicitly in http-headers.xml
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+
+	// Etag or modification date in request matches? -> content has not chanced
+	if status := checkPreconditions(r, etag, lastModified); status != 0 {
+		w.WriteHeader(status)
+		return
+	}
+
+	w.Header().Set("Content-Type", mimeType)
+
+	if f != nil && strings.ToUpper(r.Method) == "GET" && r.Header.Get("Range") != "" && ifRange(r, etag, lastModified) {
+		if serveRanges(w, r, content, mimeType) {
+			return
+		}
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+
+	if strings.ToUpper(r.Method) == "HEAD" {
+		// don't send body if HTTP Method is HEAD
+		return
 	}
 
 	// write content to response
@@ -322,6 +538,172 @@ func (core *Core) HTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// render render context.Node through its template chain, add the draft banner for drafts and minify the result
+func (core *Core) render(context *Context) (*renderedPage, error) {
+	node := context.Node
+
+	t := core.nodeTemplate(node)
+	if t == nil {
+		return nil, fmt.Errorf("template '%s' not found", node.Template())
+	}
+
+	for {
+		var buf bytes.Buffer
+		gt := template.New(t.Name())
+		gt, err := gt.Parse(t.Content())
+		if err != nil {
+			return nil, err
+		}
+
+		err = gt.Execute(&buf, context)
+		if err != nil {
+			return nil, err
+		}
+
+		context.Content = buf.String()
+
+		if t.Parent() == "" {
+			break
+		}
+
+		t = core.Templates[t.Parent()]
+		if t == nil {
+			return nil, fmt.Errorf("parent template of '%s' not found", node.Template())
+		}
+	}
+
+	if context.Draft && t.MimeType() == "text/html" {
+		context.Content = draftBanner(context.Content)
+	}
+
+	page := context.Content
+
+	// Minify the content (unless disabled in site.xml)
+	if core.Site.Minify() {
+		m, err := core.minifier.String(t.MimeType(), context.Content)
+		if err != nil {
+			// If minifying goes wrong for any reason, we leave the original content untouched and continue
+			minifyErrors.Inc()
+			log.Warn().Msg(err.Error())
+		} else {
+			page = m
+		}
+	}
+
+	return &renderedPage{
+		Content:      []byte(page),
+		MimeType:     t.MimeType(),
+		ETag:         newETag([]byte(page)),
+		LastModified: core.LastModified(node),
+	}, nil
+}
+
+// nodeTemplate return the template of node, or the default template from site.xml if the node has none
+func (core *Core) nodeTemplate(node *Node) *Template {
+	if node.Template() == "" {
+		return core.Templates[core.Site.DefaultTemplate()]
+	}
+
+	return core.Templates[node.Template()]
+}
+
+// FindPublicFile return public file for urlpath, nil if there is none
+func (core *Core) FindPublicFile(urlpath string) *PublicFile {
+	return core.PublicFiles[strings.Trim(strings.ToLower(urlpath), "/")]
+}
+
+// CacheControl return value of the Cache-Control header for a rendered node, taken from the node itself,
+// the first template in the template chain that sets it, or the default from caching.xml (in that order)
+func (core *Core) CacheControl(node *Node) string {
+	if cc := node.CacheControl(); cc != "" {
+		return cc
+	}
+
+	t := core.nodeTemplate(node)
+	for t != nil {
+		if cc := t.CacheControl(); cc != "" {
+			return cc
+		}
+
+		if t.Parent() == "" {
+			break
+		}
+
+		t = core.Templates[t.Parent()]
+	}
+
+	return core.Caching.Node()
+}
+
+// LastModified return the modification time of a rendered node, i.e. the latest of all templates in the template
+// chain and their content files, and of all nodes, not only node itself: templates may list any node (e.g. the
+// children of node), so the page changes whenever a published node is modified, gets published or expires (zero
+// time if none of them is known)
+func (core *Core) LastModified(node *Node) time.Time {
+	var lm time.Time
+
+	if node.LastModified() > 0 {
+		lm = time.Unix(int64(node.LastModified()), 0)
+	}
+
+	now := time.Now()
+	for _, n := range core.Nodes {
+		if !n.enabled() {
+			continue
+		}
+
+		times := []time.Time{n.PublishAt(), n.ExpireAt()}
+		if n.LastModified() > 0 && n.Published(now) {
+			times = append(times, time.Unix(int64(n.LastModified()), 0))
+		}
+
+		for _, t := range times {
+			if !t.After(now) && t.After(lm) {
+				lm = t
+			}
+		}
+	}
+
+	t := core.nodeTemplate(node)
+	for t != nil {
+		if tlm, err := t.LastModified(); err == nil && tlm > 0 && time.Unix(int64(tlm), 0).After(lm) {
+			lm = time.Unix(int64(tlm), 0)
+		}
+
+		if t.ContentFileModTime().After(lm) {
+			lm = t.ContentFileModTime()
+		}
+
+		if t.Parent() == "" {
+			break
+		}
+
+		t = core.Templates[t.Parent()]
+	}
+
+	return lm
+}
+
+func (core *Core) populateSite(filename string) {
+	var s bytes.Buffer
+
+	file, err := afero.ReadFile(*core.fs, filename)
+	if err != nil {
+		s.WriteString(" - ")
+		s.WriteString(err.Error())
+		log.Warn().Msg(s.String())
+
+		return
+	}
+
+	err = core.Site.Read(file)
+	if err != nil {
+		s.WriteString(" - ")
+		s.WriteString(err.Error())
+		log.Warn().Msg(s.String())
+	}
+}
+
 func (core *Core) populateHeaders(filename string) {
 	var s bytes.Buffer
 
@@ -346,6 +728,46 @@ func (core *Core) populateHeaders(filename string) {
 	}
 }
 
+func (core *Core) populateCaching(filename string) {
+	var s bytes.Buffer
+
+	file, err := afero.ReadFile(*core.fs, filename)
+	if err != nil {
+		s.WriteString(" - ")
+		s.WriteString(err.Error())
+		log.Warn().Msg(s.String())
+
+		return
+	}
+
+	err = core.Caching.Read(file)
+	if err != nil {
+		s.WriteString(" - ")
+		s.WriteString(err.Error())
+		log.Warn().Msg(s.String())
+	}
+}
+
+func (core *Core) populateSecurity(filename string) {
+	var s bytes.Buffer
+
+	file, err := afero.ReadFile(*core.fs, filename)
+	if err != nil {
+		s.WriteString(" - ")
+		s.WriteString(err.Error())
+		log.Warn().Msg(s.String())
+
+		return
+	}
+
+	err = core.Security.Read(file)
+	if err != nil {
+		s.WriteString(" - ")
+		s.WriteString(err.Error())
+		log.Warn().Msg(s.String())
+	}
+}
+
 func (core *Core) populatePublicFiles(dir string) {
 	var s bytes.Buffer
 
@@ -373,9 +795,17 @@ func (core *Core) populatePublicFiles(dir string) {
 				return nil
 			}
 
+			mimeType := TIhttp.MimeTypeByExtension(filepath.Ext(path))
+
 			core.PublicFiles[p] = &PublicFile{
 				Content:  file,
-				MimeType: TIhttp.MimeTypeByExtension(filepath.Ext(path)),
+				MimeType: mimeType,
+				ModTime:  info.ModTime(),
+				ETag:     newETag(file),
+			}
+
+			if core.Site.Compression() {
+				core.PublicFiles[p].Encoded = precompress(file, mimeType)
 			}
 		}
 
@@ -424,7 +854,8 @@ func (core *Core) populateTemplates(dir string) {
 				templ.name = p
 
 				if templ.ContentFile() != "" {
-					file, err = afero.ReadFile(*core.fs, filepath.Join(filepath.Dir(path), templ.ContentFile()))
+					cf := filepath.Join(filepath.Dir(path), templ.ContentFile())
+					file, err = afero.ReadFile(*core.fs, cf)
 					if err != nil {
 						s.WriteString(" - ")
 						s.WriteString(err.Error())
@@ -432,6 +863,10 @@ func (core *Core) populateTemplates(dir string) {
 						return nil
 					}
 
+					if fi, err := (*core.fs).Stat(cf); err == nil {
+						templ.contentFileModTime = fi.ModTime()
+					}
+
 					s.WriteString(" (using ")
 					s.WriteString(templ.ContentFile())
 					s.WriteString(") ")
@@ -501,6 +936,12 @@ func (core *Core) nodesFromDir(dir string) []*Node {
 						return nil
 					}
 
+					for _, ts := range []string{node.xmlNode.PublishAt, node.xmlNode.ExpireAt} {
+						if _, err := parseTimestamp(ts); err != nil {
+							log.Warn().Msg(fmt.Sprintf("%s - %s (node will not be published)", s.String(), err.Error()))
+						}
+					}
+
 					//log.Debug().Msg(fmt.Sprintf("reading node %s", node.Path()))
 
 					nodes = append(nodes, &node)
@@ -525,16 +966,46 @@ func (core *Core) nodesFromDir(dir string) []*Node {
 	return nodes
 }
 
-func (core *Core) populateFTIndex() {
-	log.Info().Msg("indexing Nodes ... ")
+// schedulePublishing flush the page cache and add nodes to / remove nodes from the search index (if any) when they get
+// published or expire
+// (see 'publish-at' and 'expire-at'), starting with the first point in time after since
+func (core *Core) schedulePublishing(since time.Time) {
+	var next time.Time
 
 	for _, node := range core.Nodes {
-		if node.Enabled() {
-			ns := NewNodeSearchable(node)
+		for _, t := range []time.Time{node.PublishAt(), node.ExpireAt()} {
+			if t.After(since) && (next.IsZero() || t.Before(next)) {
+				next = t
+			}
+		}
+	}
 
-			//log.Debug().Msg(fmt.Sprintf("indexing node %s", node.Path()))
+	if next.IsZero() {
+		return
+	}
+
+	time.AfterFunc(time.Until(next), func() {
+		for _, node := range core.Nodes {
+			if !node.PublishAt().Equal(next) && !node.ExpireAt().Equal(next) {
+				continue
+			}
 
-			core.ftindex.Index(string(node.Path()), ns)
+			if node.enabled() && node.Published(next) {
+				log.Info().Msg(fmt.Sprintf("publishing node %s", node.Path()))
+				if core.ftindex != nil {
+					core.indexNode(node)
+				}
+			} else {
+				log.Info().Msg(fmt.Sprintf("unpublishing node %s", node.Path()))
+				if core.ftindex != nil {
+					core.unindexNode(node)
+				}
+			}
 		}
-	}
+
+		// rendered pages may list nodes that have just been (un)published
+		core.flushPages()
+
+		core.schedulePublishing(next)
+	})
 }
@@ -0,0 +1,570 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// exportRedirect redirect of a static export, e.g. of a node with 'redirect-to'
+type exportRedirect struct {
+	From string
+	To   string
+}
+
+// exportHeaders headers of a static export for paths matching Expression (doublestar, see http-headers.xml),
+// all paths if Expression is ""
+type exportHeaders struct {
+	Expression string
+	Header     http.Header
+}
+
+// exportEntry file of a static export, source is what it has been generated from (for collision reports)
+type exportEntry struct {
+	Path    string
+	Source  string
+	Content []byte
+}
+
+// Export write the site to dir as static files: public files, enabled nodes rendered and minified as by Core.HTTP,
+// redirect stubs (meta refresh) and the redirects and headers as configuration for Netlify (_redirects, _headers).
+// With configDir the redirects and headers are written there as well, outside of dir so that they are not
+// published: for Apache httpd (apache.conf) and NGINX (nginx.conf), both to be included in the configuration of the
+// virtual host / server block.
+// Nodes are written to files named like their path (e.g. 'about'), with pretty HTML nodes to 'about/index.html'
+// unless the path ends with .html already. Static hosts go by the file name extension, so for nodes whose file does
+// not carry the extension of their mime type (e.g. 'about', 'feed' or 'v1.2') the Content-Type header is set.
+// Nothing is written if files collide, e.g. a node written as file that is the directory of another one.
+func (core *Core) Export(dir string, configDir string, pretty bool) error {
+	if configDir != "" {
+		d, _ := filepath.Abs(dir)
+		c, _ := filepath.Abs(configDir)
+		if rel, err := filepath.Rel(d, c); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("server configuration %s must not be written to %s, it would be published", configDir, dir)
+		}
+	}
+
+	// static files cannot carry a nonce per request, so all pages share one
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+
+	var entries []exportEntry
+
+	var paths []string
+	for p := range core.PublicFiles {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		entries = append(entries, exportEntry{Path: p, Source: "public/" + p, Content: core.PublicFiles[p].Content})
+	}
+
+	var redirects []exportRedirect
+	types := make(map[string]string)
+
+	for _, node := range core.Nodes {
+		if !node.Enabled() {
+			continue
+		}
+
+		p := strings.TrimPrefix(string(node.Path()), "/")
+		if core.PublicFiles[p] != nil {
+			// public files take precedence over nodes, see Core.HTTP
+			log.Warn().Msg(fmt.Sprintf("%s: skipped, there is a public file with the same path", node.Path()))
+			continue
+		}
+
+		source := "node " + string(node.Path())
+
+		if to := node.RedirectTo(); to != "" {
+			redirects = append(redirects, exportRedirect{From: string(node.Path()), To: to})
+			entries = append(entries, exportEntry{Path: exportPath(p, "text/html", pretty), Source: source, Content: redirectStub(to)})
+			continue
+		}
+
+		var form *FormState
+		if node.Form() != nil {
+			log.Warn().Msg(fmt.Sprintf("%s: form submissions require onacms to be running", node.Path()))
+			form = &FormState{}
+		}
+
+		// templates may use the request, e.g. query parameters, so nodes are rendered as if requested without any
+		r, err := http.NewRequest("GET", core.Site.URL(node.Path()), nil)
+		if err != nil {
+			return fmt.Errorf("%s: %s", node.Path(), err.Error())
+		}
+
+		context := Context{
+			HTTPRequest:   r,
+			Node:          node,
+			Content:       node.Render(),
+			AllNodes:      core.Nodes,
+			PublicFiles:   core.PublicFiles,
+			FulltextIndex: core.ftindex,
+			Relations:     core.Relations,
+			CSPNonce:      core.noncePlaceholder,
+			Site:          core.Site,
+			Form:          form,
+		}
+
+		page, err := core.render(&context)
+		if err != nil {
+			renderErrors.Inc()
+			return fmt.Errorf("%s: %s", node.Path(), err.Error())
+		}
+
+		f := exportPath(p, page.MimeType, pretty)
+		if f == p && !exportTyped(p, page.MimeType) {
+			types[strings.ToLower(p)] = page.MimeType + "; charset=UTF-8"
+		}
+
+		entries = append(entries, exportEntry{
+			Path:    f,
+			Source:  source,
+			Content: bytes.Replace(page.Content, []byte(core.noncePlaceholder), []byte(nonce), -1),
+		})
+	}
+
+	// the home page redirects to the root node in the language of the visitor, static hosts get the default
+	languages := make(map[string]string)
+	var home string
+	defaultLanguage := false
+	for _, node := range RootNodes(core.Nodes) {
+		if !node.Enabled() {
+			continue
+		}
+
+		if _, ok := languages[node.Language()]; !ok && node.Language() != "" {
+			languages[node.Language()] = string(node.Path())
+		}
+
+		if home == "" || !defaultLanguage && node.Language() == core.Site.DefaultLanguage() {
+			home = string(node.Path())
+			defaultLanguage = node.Language() == core.Site.DefaultLanguage()
+		}
+	}
+
+	if home != "" && core.PublicFiles["index.html"] == nil {
+		entries = append(entries, exportEntry{Path: "index.html", Source: "home page", Content: redirectStub(home)})
+	}
+
+	headers := []exportHeaders{{Header: core.Security.Headers(nonce)}}
+	for _, uri := range core.HTTPHeaders.URI {
+		h := make(http.Header)
+		for _, v := range uri.Header {
+			kv := strings.SplitN(v, ":", 2)
+			if len(kv) == 2 {
+				h.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+			}
+		}
+		headers = append(headers, exportHeaders{Expression: strings.ToLower(uri.Expression), Header: h})
+	}
+
+	// the paths of nodes are expressions that match nothing but the path
+	var typed []string
+	for p := range types {
+		typed = append(typed, p)
+	}
+	sort.Strings(typed)
+	for _, p := range typed {
+		found := false
+		for _, e := range headers {
+			if e.Expression == p {
+				e.Header.Set("Content-Type", types[p])
+				found = true
+			}
+		}
+		if !found {
+			headers = append(headers, exportHeaders{Expression: p, Header: http.Header{"Content-Type": {types[p]}}})
+		}
+	}
+
+	entries = append(entries,
+		exportEntry{Path: "_redirects", Source: "Netlify redirects", Content: netlifyRedirects(redirects, languages, home)},
+		exportEntry{Path: "_headers", Source: "Netlify headers", Content: netlifyHeaders(headers)},
+	)
+
+	if err := exportCollisions(entries, pretty); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := exportFile(dir, e.Path, e.Content); err != nil {
+			return err
+		}
+	}
+	log.Info().Msg(fmt.Sprintf("%d file(s) exported", len(entries)))
+
+	if configDir != "" {
+		if err := exportFile(configDir, "apache.conf", apacheConfig(redirects, headers, home)); err != nil {
+			return err
+		}
+		if err := exportFile(configDir, "nginx.conf", nginxConfig(redirects, headers, home)); err != nil {
+			return err
+		}
+		log.Info().Msg(fmt.Sprintf("server configuration written to %s", configDir))
+	}
+
+	return nil
+}
+
+// exportPath return path of the file a node with path p (without leading slash) and mimeType is written to
+func exportPath(p string, mimeType string, pretty bool) string {
+	if !pretty || p == "" || exportMimeType(mimeType) != "text/html" || exportTyped(p, mimeType) {
+		return p
+	}
+
+	return p + "/index.html"
+}
+
+// exportTyped return if static hosts serve the file p as mimeType, going by its file name extension
+func exportTyped(p string, mimeType string) bool {
+	ext := path.Ext(p)
+
+	return ext != "" && exportMimeType(mime.TypeByExtension(ext)) == exportMimeType(mimeType)
+}
+
+// exportMimeType return mimeType in lower case without parameters (e.g. charset)
+func exportMimeType(mimeType string) string {
+	return strings.ToLower(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0]))
+}
+
+// exportCollisions return error listing all files of an export that collide: files written more than once and files
+// that are needed as directory by others, nil if there are none
+func exportCollisions(entries []exportEntry, pretty bool) error {
+	files := make(map[string]string)
+	var collisions []string
+
+	for _, e := range entries {
+		p := strings.ToLower(e.Path)
+		if s, ok := files[p]; ok {
+			collisions = append(collisions, fmt.Sprintf("%s: %s and %s", e.Path, s, e.Source))
+			continue
+		}
+		files[p] = e.Source
+	}
+
+	for _, e := range entries {
+		for d := path.Dir(strings.ToLower(e.Path)); d != "." && d != "/"; d = path.Dir(d) {
+			if s, ok := files[d]; ok {
+				collisions = append(collisions, fmt.Sprintf("%s: file of %s, directory of %s", d, s, e.Source))
+			}
+		}
+	}
+
+	if len(collisions) == 0 {
+		return nil
+	}
+
+	sort.Strings(collisions)
+	for _, c := range collisions {
+		log.Error().Msg(fmt.Sprintf("export: collision %s", c))
+	}
+
+	if !pretty {
+		return fmt.Errorf("%d collision(s), try --pretty-urls", len(collisions))
+	}
+
+	return fmt.Errorf("%d collision(s)", len(collisions))
+}
+
+// exportFile write content to p (slash separated, relative to dir)
+func exportFile(dir string, p string, content []byte) error {
+	f := filepath.Join(dir, filepath.FromSlash(p))
+
+	if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(f, content, 0644); err != nil {
+		return err
+	}
+
+	log.Debug().Msg(fmt.Sprintf("%s ok", f))
+
+	return nil
+}
+
+// redirectStub return HTML page redirecting to url, for static hosts that do not read any of the redirect files
+func redirectStub(url string) []byte {
+	u := html.EscapeString(url)
+
+	return []byte(`<!DOCTYPE html><html><head><meta charset="utf-8"><title>Redirecting&hellip;</title>` +
+		`<meta http-equiv="refresh" content="0; url=` + u + `"><link rel="canonical" href="` + u + `">` +
+		`<meta name="robots" content="noindex"></head><body><a href="` + u + `">` + u + `</a></body></html>`)
+}
+
+// globRegexp return regular expression for a doublestar expression matching paths without leading slash
+func globRegexp(expression string) string {
+	var b strings.Builder
+	b.WriteString("^/")
+
+	braces := 0
+	for i := 0; i < len(expression); i++ {
+		c := expression[i]
+		switch {
+		case c == '*' && i+1 < len(expression) && expression[i+1] == '*':
+			i++
+			if i+1 < len(expression) && expression[i+1] == '/' {
+				// '**/' matches any number of directories, none included
+				i++
+				b.WriteString("(.*/)?")
+			} else {
+				b.WriteString(".*")
+			}
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '{':
+			braces++
+			b.WriteString("(")
+		case c == '}' && braces > 0:
+			braces--
+			b.WriteString(")")
+		case c == ',' && braces > 0:
+			b.WriteString("|")
+		case c == '[':
+			j := strings.IndexByte(expression[i:], ']')
+			if j < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := expression[i+1 : i+j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += j
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	b.WriteString("$")
+
+	return b.String()
+}
+
+// netlifyPath return Netlify path for a doublestar expression, false if it cannot be expressed with splats
+func netlifyPath(expression string) (string, bool) {
+	if strings.ContainsAny(expression, "?[]{}") {
+		return "", false
+	}
+
+	for strings.Contains(expression, "**") {
+		expression = strings.Replace(expression, "**", "*", -1)
+	}
+
+	return "/" + expression, true
+}
+
+// sortedHeaders return names of the headers in h in alphabetical order
+func sortedHeaders(h http.Header) []string {
+	var keys []string
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// nginxQuote return v in double quotes as read by NGINX, which unescapes backslashes
+func nginxQuote(v string) string {
+	return `"` + strings.Replace(strings.Replace(v, `\`, `\\`, -1), `"`, `\"`, -1) + `"`
+}
+
+// apacheQuote return v in double quotes as read by Apache httpd, which unescapes quotes only
+func apacheQuote(v string) string {
+	return `"` + strings.Replace(v, `"`, `\"`, -1) + `"`
+}
+
+// netlifyRedirects return redirects in the format of Netlify (_redirects)
+func netlifyRedirects(redirects []exportRedirect, languages map[string]string, home string) []byte {
+	var b bytes.Buffer
+	b.WriteString("# generated by onacms\n")
+
+	for _, r := range redirects {
+		b.WriteString(fmt.Sprintf("%s %s 302\n", r.From, r.To))
+	}
+
+	var ls []string
+	for l := range languages {
+		ls = append(ls, l)
+	}
+	sort.Strings(ls)
+	for _, l := range ls {
+		b.WriteString(fmt.Sprintf("/ %s 302 Language=%s\n", languages[l], l))
+	}
+	if home != "" {
+		b.WriteString(fmt.Sprintf("/ %s 302\n", home))
+	}
+
+	return b.Bytes()
+}
+
+// netlifyHeaders return headers in the format of Netlify (_headers)
+func netlifyHeaders(headers []exportHeaders) []byte {
+	var b bytes.Buffer
+	b.WriteString("# generated by onacms\n")
+
+	for _, h := range headers {
+		p := "/*"
+		if h.Expression != "" {
+			var ok bool
+			if p, ok = netlifyPath(h.Expression); !ok {
+				log.Warn().Msg(fmt.Sprintf("_headers: '%s' cannot be expressed for Netlify, skipped", h.Expression))
+				continue
+			}
+		}
+
+		b.WriteString(p + "\n")
+		for _, k := range sortedHeaders(h.Header) {
+			for _, v := range h.Header[k] {
+				b.WriteString(fmt.Sprintf("  %s: %s\n", k, v))
+			}
+		}
+	}
+
+	return b.Bytes()
+}
+
+// apacheConfig return redirects and headers in the format of Apache httpd (mod_alias and mod_headers), to be included
+// in the virtual host of the site
+func apacheConfig(redirects []exportRedirect, headers []exportHeaders, home string) []byte {
+	var b bytes.Buffer
+	b.WriteString("# generated by onacms, include in the virtual host of the site\n")
+
+	b.WriteString("<IfModule mod_alias.c>\n")
+	for _, r := range redirects {
+		b.WriteString(fmt.Sprintf("    RedirectMatch 302 %s %s\n", apacheQuote("^"+regexp.QuoteMeta(r.From)+"$"), apacheQuote(r.To)))
+	}
+	if home != "" {
+		b.WriteString(fmt.Sprintf("    RedirectMatch 302 \"^/$\" %s\n", apacheQuote(home)))
+	}
+	b.WriteString("</IfModule>\n")
+
+	b.WriteString("<IfModule mod_headers.c>\n")
+	for _, h := range headers {
+		indent := "    "
+		if h.Expression != "" {
+			b.WriteString(fmt.Sprintf("    <If \"%%{REQUEST_URI} =~ m#%s#\">\n", globRegexp(h.Expression)))
+			indent += "    "
+		}
+
+		for _, k := range sortedHeaders(h.Header) {
+			if k == "Content-Type" {
+				// mod_headers would send a second Content-Type
+				b.WriteString(fmt.Sprintf("%sForceType %s\n", indent, apacheQuote(h.Header.Get(k))))
+				continue
+			}
+
+			for i, v := range h.Header[k] {
+				action := "set"
+				if i > 0 {
+					action = "add"
+				}
+				b.WriteString(fmt.Sprintf("%sHeader always %s %s %s\n", indent, action, k, apacheQuote(v)))
+			}
+		}
+
+		if h.Expression != "" {
+			b.WriteString("    </If>\n")
+		}
+	}
+	b.WriteString("</IfModule>\n")
+
+	return b.Bytes()
+}
+
+// nginxConfig return redirects and headers in the format of NGINX, to be included in the server block of the site.
+// add_header in a location replaces all add_header of the server block, so every location repeats the headers for
+// all paths (unless replaced); NGINX uses an exact location or else the first matching regular expression location
+// only.
+func nginxConfig(redirects []exportRedirect, headers []exportHeaders, home string) []byte {
+	var b bytes.Buffer
+	b.WriteString("# generated by onacms, include in the server block of the site\n")
+
+	var all http.Header
+	for _, h := range headers {
+		if h.Expression == "" {
+			all = h.Header
+		}
+	}
+
+	write := func(indent string, h http.Header) {
+		if ct := h.Get("Content-Type"); ct != "" {
+			// add_header would send a second Content-Type
+			b.WriteString(fmt.Sprintf("%stypes { }\n%sdefault_type %s;\n", indent, indent, nginxQuote(ct)))
+		}
+
+		for _, k := range sortedHeaders(h) {
+			if k == "Content-Type" {
+				continue
+			}
+
+			for _, v := range h[k] {
+				b.WriteString(fmt.Sprintf("%sadd_header %s %s always;\n", indent, k, nginxQuote(v)))
+			}
+		}
+	}
+
+	write("", all)
+
+	redirected := make(map[string]bool)
+	for _, r := range redirects {
+		redirected[strings.ToLower(strings.TrimPrefix(r.From, "/"))] = true
+		b.WriteString(fmt.Sprintf("location = %s {\n    return 302 %s;\n}\n", r.From, nginxQuote(r.To)))
+	}
+	if home != "" {
+		b.WriteString(fmt.Sprintf("location = / {\n    return 302 %s;\n}\n", nginxQuote(home)))
+	}
+
+	for _, h := range headers {
+		if h.Expression == "" {
+			continue
+		}
+
+		merged := h.Header.Clone()
+		merge := func(o http.Header) {
+			for k, v := range o {
+				if _, ok := merged[k]; !ok {
+					merged[k] = v
+				}
+			}
+		}
+
+		location := "~ " + nginxQuote(globRegexp(h.Expression))
+		if !strings.ContainsAny(h.Expression, "*?[]{}") && !redirected[h.Expression] {
+			// exact locations take precedence over regular expressions, so they get the headers of those matching
+			location = "= " + nginxQuote("/"+h.Expression)
+			for _, o := range headers {
+				if o.Expression == "" || o.Expression == h.Expression {
+					continue
+				}
+				if re, err := regexp.Compile(globRegexp(o.Expression)); err == nil && re.MatchString("/"+h.Expression) {
+					merge(o.Header)
+				}
+			}
+		}
+		merge(all)
+
+		b.WriteString(fmt.Sprintf("location %s {\n", location))
+		write("    ", merged)
+		b.WriteString("}\n")
+	}
+
+	return b.Bytes()
+}
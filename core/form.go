@@ -0,0 +1,410 @@
+package core
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/units"
+)
+
+const (
+	// CSRFCookie name of the cookie the CSRF token of forms is bound to
+	CSRFCookie = "onacms-csrf"
+
+	// CSRFField name of the form field carrying the CSRF token
+	CSRFField = "csrf"
+
+	// defaultFormMaxSize maximum size of a form submission unless set with 'max-size'
+	defaultFormMaxSize = 64 * units.KiB
+)
+
+// XMLForm xml representation of a form endpoint (node: 'form')
+type XMLForm struct {
+	ThankYou    string         `xml:"thank-you"`
+	Store       string         `xml:"store"`
+	MailTo      string         `xml:"mail-to"`
+	MailSubject string         `xml:"mail-subject"`
+	Honeypot    string         `xml:"honeypot"`
+	MaxSize     string         `xml:"max-size"`
+	Field       []XMLFormField `xml:"field"`
+}
+
+// XMLFormField xml representation of a form field
+type XMLFormField struct {
+	Name      string `xml:"name,attr"`
+	Label     string `xml:"label,attr"`
+	Type      string `xml:"type,attr"`
+	Required  string `xml:"required,attr"`
+	MinLength string `xml:"min-length,attr"`
+	MaxLength string `xml:"max-length,attr"`
+	Pattern   string `xml:"pattern,attr"`
+}
+
+// Form form endpoint of a node
+type Form struct {
+	xmlForm *XMLForm
+}
+
+// ThankYou return path of the node to redirect to after a successful submission, "" for the form itself
+// (from: 'thank-you')
+func (f *Form) ThankYou() string {
+	return strings.TrimSpace(f.xmlForm.ThankYou)
+}
+
+// Store return if submissions are stored as JSON lines (from: 'store', defaults to true)
+func (f *Form) Store() bool {
+	return toggle(f.xmlForm.Store, true)
+}
+
+// MailTo return recipients of submissions, none if submissions are not sent by mail (from: 'mail-to')
+func (f *Form) MailTo() []string {
+	var to []string
+
+	for _, a := range strings.Split(f.xmlForm.MailTo, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			to = append(to, a)
+		}
+	}
+
+	return to
+}
+
+// MailSubject return subject of mails (from: 'mail-subject')
+func (f *Form) MailSubject() string {
+	return strings.TrimSpace(f.xmlForm.MailSubject)
+}
+
+// Honeypot return name of the field that must be left empty, "" if there is none (from: 'honeypot')
+func (f *Form) Honeypot() string {
+	return strings.TrimSpace(f.xmlForm.Honeypot)
+}
+
+// MaxSize return maximum size of a submission (from: 'max-size', defaults to 64KiB)
+func (f *Form) MaxSize() int64 {
+	v := strings.TrimSpace(f.xmlForm.MaxSize)
+	if v == "" {
+		return int64(defaultFormMaxSize)
+	}
+
+	b, err := units.ParseBase2Bytes(v)
+	if err != nil || b <= 0 {
+		log.Warn().Msg(fmt.Sprintf("form: max-size: invalid value '%s'", v))
+		return int64(defaultFormMaxSize)
+	}
+
+	return int64(b)
+}
+
+// Fields return fields of the form
+func (f *Form) Fields() []*FormField {
+	var fields []*FormField
+
+	for i := range f.xmlForm.Field {
+		fields = append(fields, &FormField{xmlFormField: &f.xmlForm.Field[i]})
+	}
+
+	return fields
+}
+
+// FormField field of a form
+type FormField struct {
+	xmlFormField *XMLFormField
+}
+
+// Name return field name (from: 'name')
+func (ff *FormField) Name() string {
+	return strings.TrimSpace(ff.xmlFormField.Name)
+}
+
+// Label return field label, the name if not set (from: 'label')
+func (ff *FormField) Label() string {
+	if l := strings.TrimSpace(ff.xmlFormField.Label); l != "" {
+		return l
+	}
+
+	return ff.Name()
+}
+
+// Type return field type: text, email, url or number (from: 'type', defaults to text)
+func (ff *FormField) Type() string {
+	if t := strings.ToLower(strings.TrimSpace(ff.xmlFormField.Type)); t != "" {
+		return t
+	}
+
+	return "text"
+}
+
+// Required return if the field must not be empty (from: 'required')
+func (ff *FormField) Required() bool {
+	return toggle(ff.xmlFormField.Required, false)
+}
+
+// MinLength return minimum length in characters, 0 if not set (from: 'min-length')
+func (ff *FormField) MinLength() int {
+	l, _ := strconv.Atoi(strings.TrimSpace(ff.xmlFormField.MinLength))
+	return l
+}
+
+// MaxLength return maximum length in characters, 0 if not set (from: 'max-length')
+func (ff *FormField) MaxLength() int {
+	l, _ := strconv.Atoi(strings.TrimSpace(ff.xmlFormField.MaxLength))
+	return l
+}
+
+// Pattern return regular expression the whole value must match, "" if not set (from: 'pattern')
+func (ff *FormField) Pattern() string {
+	return strings.TrimSpace(ff.xmlFormField.Pattern)
+}
+
+// Validate return error message if value is not valid for the field, "" otherwise
+func (ff *FormField) Validate(value string) string {
+	if value == "" {
+		if ff.Required() {
+			return "required"
+		}
+		return ""
+	}
+
+	l := utf8.RuneCountInString(value)
+	if min := ff.MinLength(); min > 0 && l < min {
+		return fmt.Sprintf("at least %d characters", min)
+	}
+	if max := ff.MaxLength(); max > 0 && l > max {
+		return fmt.Sprintf("at most %d characters", max)
+	}
+
+	switch ff.Type() {
+	case "email":
+		if a, err := mail.ParseAddress(value); err != nil || a.Address != value {
+			return "invalid email address"
+		}
+	case "url":
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "invalid URL"
+		}
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "invalid number"
+		}
+	}
+
+	if p := ff.Pattern(); p != "" {
+		re, err := regexp.Compile("^(?:" + p + ")$")
+		if err != nil {
+			log.Warn().Msg(fmt.Sprintf("form field '%s': pattern: %s", ff.Name(), err.Error()))
+		} else if !re.MatchString(value) {
+			return "invalid format"
+		}
+	}
+
+	return ""
+}
+
+// FormState values and validation errors of a form, available to templates as .Form
+type FormState struct {
+	Values  map[string]string
+	Errors  map[string]string
+	Failure string
+}
+
+// Value return submitted value of field name, e.g. to fill in the form again
+func (fs *FormState) Value(name string) string {
+	if fs == nil {
+		return ""
+	}
+
+	return fs.Values[name]
+}
+
+// Error return validation error of field name, "" if there is none
+func (fs *FormState) Error(name string) string {
+	if fs == nil {
+		return ""
+	}
+
+	return fs.Errors[name]
+}
+
+// HasErrors return if any field is not valid
+func (fs *FormState) HasErrors() bool {
+	return fs != nil && len(fs.Errors) > 0
+}
+
+// csrfToken return CSRF token for the form of node, bound to the CSRF cookie, which is set if there is none
+func (core *Core) csrfToken(w http.ResponseWriter, r *http.Request, node *Node) (string, error) {
+	if c, err := r.Cookie(CSRFCookie); err == nil && c.Value != "" {
+		return core.signCSRF(c.Value, node), nil
+	}
+
+	v, err := newNonce()
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookie,
+		Value:    v,
+		Path:     "/",
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return core.signCSRF(v, node), nil
+}
+
+// validCSRF return if the CSRF token submitted matches the CSRF cookie
+func (core *Core) validCSRF(r *http.Request, node *Node) bool {
+	c, err := r.Cookie(CSRFCookie)
+	if err != nil || c.Value == "" {
+		return false
+	}
+
+	return hmac.Equal([]byte(r.PostForm.Get(CSRFField)), []byte(core.signCSRF(c.Value, node)))
+}
+
+// SetFormSecret sign CSRF tokens with a key derived from secret instead of a random one, so that forms rendered
+// before a restart or by another instance can still be submitted
+func (core *Core) SetFormSecret(secret string) {
+	if secret == "" {
+		return
+	}
+
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(CSRFCookie))
+	core.csrfKey = m.Sum(nil)
+}
+
+func (core *Core) signCSRF(cookie string, node *Node) string {
+	m := hmac.New(sha256.New, core.csrfKey)
+	m.Write([]byte(cookie + "|" + string(node.Path())))
+
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// submitForm handle submission (POST) of the form of the node at urlpath: store and/or mail it and redirect
+// to the thank-you node, or render the form again with errors
+func (core *Core) submitForm(w http.ResponseWriter, r *http.Request, urlpath string, nonce string) {
+	node := FindNode(urlpath, core.Nodes)
+	if node == nil || node.Form() == nil {
+		// POST is for forms only
+		w.Header().Set("Allow", "GET, HEAD")
+		w.WriteHeader(405)
+		return
+	}
+
+	info := requestInfo(r)
+	info.Resolution = ResolutionForm
+	info.Target = string(node.Path())
+
+	form := node.Form()
+
+	r.Body = http.MaxBytesReader(w, r.Body, form.MaxSize())
+
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		err = r.ParseMultipartForm(form.MaxSize())
+	} else {
+		err = r.ParseForm()
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "request entity too large", 413)
+			return
+		}
+		http.Error(w, "bad request", 400)
+		return
+	}
+
+	validCSRF := core.validCSRF(r, node)
+
+	thankYou := string(node.Path())
+	if form.ThankYou() != "" {
+		thankYou = form.ThankYou()
+	}
+
+	if hp := form.Honeypot(); validCSRF && hp != "" && r.PostForm.Get(hp) != "" {
+		// pretend success, so that bots do not learn about the honeypot
+		log.Info().Msg(fmt.Sprintf("%s: form submission caught by honeypot", node.Path()))
+		http.Redirect(w, r, thankYou, 303)
+		return
+	}
+
+	state := &FormState{Values: make(map[string]string), Errors: make(map[string]string)}
+
+	for _, ff := range form.Fields() {
+		v := strings.TrimSpace(strings.ReplaceAll(r.PostForm.Get(ff.Name()), "\r\n", "\n"))
+
+		state.Values[ff.Name()] = v
+		if e := ff.Validate(v); e != "" {
+			state.Errors[ff.Name()] = e
+		}
+	}
+
+	status := 422
+	if !validCSRF {
+		// e.g. the CSRF cookie expired or was blocked: render the form again with the values and a fresh token
+		log.Warn().Msg(fmt.Sprintf("%s: form submission with invalid CSRF token", node.Path()))
+		state.Failure = "your session has expired, please submit the form again"
+		status = 403
+	} else if !state.HasErrors() {
+		err = core.Forms.Submit(node, state.Values)
+		if err == nil {
+			http.Redirect(w, r, thankYou, 303)
+			return
+		}
+
+		log.Error().Msg(fmt.Sprintf("%s: %s", node.Path(), err.Error()))
+		state.Failure = "submission failed, please try again later"
+		status = 500
+	}
+
+	csrf, err := core.csrfToken(w, r, node)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		http.Error(w, "", 500)
+		return
+	}
+
+	context := Context{
+		HTTPRequest:   r,
+		Node:          node,
+		Content:       node.Render(),
+		AllNodes:      core.Nodes,
+		PublicFiles:   core.PublicFiles,
+		FulltextIndex: core.ftindex,
+		Relations:     core.Relations,
+		CSPNonce:      core.noncePlaceholder,
+		Site:          core.Site,
+		Form:          state,
+		CSRFToken:     csrf,
+	}
+
+	page, err := core.render(&context)
+	if err != nil {
+		renderErrors.Inc()
+		log.Error().Msg(fmt.Sprintf("%s: %s", node.Path(), err.Error()))
+		w.WriteHeader(500)
+		return
+	}
+
+	content := bytes.Replace(page.Content, []byte(core.noncePlaceholder), []byte(nonce), -1)
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Content-Type", page.MimeType+"; charset=UTF-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.WriteHeader(status)
+	w.Write(content)
+}
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Forms destinations of form submissions configured at startup: a directory for JSON lines and/or an SMTP relay
+type Forms struct {
+	dir      string
+	relay    string
+	from     string
+	username string
+	password string
+	mutex    sync.Mutex
+}
+
+// NewForms initialiser, return nil (= form submissions are refused) if neither dir nor relay is given
+func NewForms(dir string, relay string, from string, username string, password string) *Forms {
+	if dir == "" && relay == "" {
+		return nil
+	}
+
+	return &Forms{dir: dir, relay: relay, from: from, username: username, password: password}
+}
+
+// submission accepted form submission
+type submission struct {
+	Time   time.Time         `json:"time"`
+	Node   string            `json:"node"`
+	Fields map[string]string `json:"fields"`
+}
+
+// Submit store submission of the form of node and/or send it by mail, depending on the form and our configuration
+func (f *Forms) Submit(node *Node, values map[string]string) error {
+	if f == nil {
+		return errors.New("form submissions are not configured (--form-dir, --smtp-relay)")
+	}
+
+	form := node.Form()
+	s := submission{Time: time.Now().UTC(), Node: string(node.Path()), Fields: values}
+
+	stored := false
+	if form.Store() && f.dir != "" {
+		if err := f.store(s); err != nil {
+			return err
+		}
+		stored = true
+	}
+
+	mailed := false
+	if len(form.MailTo()) > 0 && f.relay != "" {
+		if err := f.mail(form, s); err != nil {
+			return err
+		}
+		mailed = true
+	}
+
+	if !stored && !mailed {
+		return fmt.Errorf("%s: submission neither stored nor sent by mail", s.Node)
+	}
+
+	return nil
+}
+
+// Deliverable return if submissions of form can be stored or sent by mail with our configuration
+func (f *Forms) Deliverable(form *Form) bool {
+	if f == nil || form == nil {
+		return false
+	}
+
+	return form.Store() && f.dir != "" || len(form.MailTo()) > 0 && f.relay != ""
+}
+
+// UndeliverableForms return nodes that are not disabled and have a form whose submissions can be neither stored nor
+// sent by mail with the configuration in core.Forms
+func (core *Core) UndeliverableForms() []*Node {
+	var nodes []*Node
+	for _, n := range core.Nodes {
+		if n.enabled() && n.Form() != nil && !core.Forms.Deliverable(n.Form()) {
+			nodes = append(nodes, n)
+		}
+	}
+
+	return nodes
+}
+
+// store append submission to '<dir>/<node path>.jsonl'
+func (f *Forms) store(s submission) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	name := strings.ReplaceAll(strings.Trim(s.Node, "/"), "/", "-") + ".jsonl"
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	file, err := os.OpenFile(filepath.Join(f.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(b, '\n'))
+
+	return err
+}
+
+// mail send submission to the recipients of form through the SMTP relay
+func (f *Forms) mail(form *Form, s submission) error {
+	to := form.MailTo()
+
+	subject := form.MailSubject()
+	if subject == "" {
+		subject = "Form submission: " + s.Node
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", f.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", headerValue(subject)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", s.Time.Format(time.RFC1123Z))
+
+	for _, ff := range form.Fields() {
+		if ff.Type() == "email" && s.Fields[ff.Name()] != "" {
+			// validated before, so it is a plain address
+			fmt.Fprintf(&msg, "Reply-To: %s\r\n", headerValue(s.Fields[ff.Name()]))
+			break
+		}
+	}
+
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+
+	for _, ff := range form.Fields() {
+		fmt.Fprintf(&msg, "%s:\r\n%s\r\n\r\n", ff.Label(), strings.ReplaceAll(s.Fields[ff.Name()], "\n", "\r\n"))
+	}
+
+	var auth smtp.Auth
+	if f.username != "" {
+		host, _, err := net.SplitHostPort(f.relay)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", f.username, f.password, host)
+	}
+
+	// STARTTLS is used if the relay supports it
+	return smtp.SendMail(f.relay, auth, f.from, to, msg.Bytes())
+}
+
+// headerValue remove line breaks, so that values cannot add mail headers
+func headerValue(v string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(v)
+}
@@ -0,0 +1,315 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	keywordAnalyzer "github.com/blevesearch/bleve/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/analysis/analyzer/standard"
+	"github.com/blevesearch/bleve/analysis/lang/ar"
+	"github.com/blevesearch/bleve/analysis/lang/cjk"
+	"github.com/blevesearch/bleve/analysis/lang/ckb"
+	"github.com/blevesearch/bleve/analysis/lang/da"
+	"github.com/blevesearch/bleve/analysis/lang/de"
+	"github.com/blevesearch/bleve/analysis/lang/en"
+	"github.com/blevesearch/bleve/analysis/lang/es"
+	"github.com/blevesearch/bleve/analysis/lang/fa"
+	"github.com/blevesearch/bleve/analysis/lang/fi"
+	"github.com/blevesearch/bleve/analysis/lang/fr"
+	"github.com/blevesearch/bleve/analysis/lang/hi"
+	"github.com/blevesearch/bleve/analysis/lang/hu"
+	"github.com/blevesearch/bleve/analysis/lang/it"
+	"github.com/blevesearch/bleve/analysis/lang/nl"
+	"github.com/blevesearch/bleve/analysis/lang/no"
+	"github.com/blevesearch/bleve/analysis/lang/pt"
+	"github.com/blevesearch/bleve/analysis/lang/ro"
+	"github.com/blevesearch/bleve/analysis/lang/ru"
+	"github.com/blevesearch/bleve/analysis/lang/sv"
+	"github.com/blevesearch/bleve/analysis/lang/tr"
+	"github.com/blevesearch/bleve/mapping"
+)
+
+const (
+	// internal keys of the search index, holding the mapping hash and the content hash of every node
+	mappingHashKey = "onacms:mapping"
+	nodeHashPrefix = "onacms:node:"
+
+	// ftIndexLockTimeout maximum duration to wait for another onacms to release the index on disk
+	ftIndexLockTimeout = 10 * time.Second
+)
+
+// errFTIndexLocked the index on disk is locked by another process
+var errFTIndexLocked = errors.New("search index is locked")
+
+// languageAnalyzers analysers shipped with bleve by language (ISO 639-1), all others use the standard analyser
+var languageAnalyzers = map[string]string{
+	"ar": ar.AnalyzerName, "ckb": ckb.AnalyzerName, "da": da.AnalyzerName, "de": de.AnalyzerName,
+	"en": en.AnalyzerName, "es": es.AnalyzerName, "fa": fa.AnalyzerName, "fi": fi.AnalyzerName,
+	"fr": fr.AnalyzerName, "hi": hi.AnalyzerName, "hu": hu.AnalyzerName, "it": it.AnalyzerName,
+	"nl": nl.AnalyzerName, "no": no.AnalyzerName, "nb": no.AnalyzerName, "nn": no.AnalyzerName,
+	"pt": pt.AnalyzerName, "ro": ro.AnalyzerName, "ru": ru.AnalyzerName, "sv": sv.AnalyzerName,
+	"tr": tr.AnalyzerName, "zh": cjk.AnalyzerName, "ja": cjk.AnalyzerName, "ko": cjk.AnalyzerName,
+}
+
+// languageAnalyzer return name of the analyser for language, e.g. 'de' for 'de-CH', the standard analyser if
+// bleve does not ship one
+func languageAnalyzer(language string) string {
+	if a, ok := languageAnalyzers[strings.Split(strings.ToLower(language), "-")[0]]; ok {
+		return a
+	}
+
+	return standard.Name
+}
+
+// documentType return type of the document mapping for nodes in language
+func documentType(language string) string {
+	a := languageAnalyzer(language)
+	if a == standard.Name {
+		return "node"
+	}
+
+	return "node_" + a
+}
+
+// newIndexMapping return mapping of the full-text index: text fields for title, description and content,
+// keyword fields for language, template and path segments, custom properties as dynamic text fields and
+// taxonomy properties as dynamic keyword fields.
+// There is a document mapping for every analyser, so that text is analysed according to the language of the node.
+func newIndexMapping() mapping.IndexMapping {
+	keyword := bleve.NewTextFieldMapping()
+	keyword.Analyzer = keywordAnalyzer.Name
+	keyword.IncludeInAll = false
+	keyword.IncludeTermVectors = false
+
+	m := bleve.NewIndexMapping()
+
+	analyzers := map[string]bool{standard.Name: true}
+	for _, a := range languageAnalyzers {
+		analyzers[a] = true
+	}
+
+	for a := range analyzers {
+		text := bleve.NewTextFieldMapping()
+		text.Analyzer = a
+
+		properties := bleve.NewDocumentMapping()
+		properties.DefaultAnalyzer = a
+
+		// taxonomy values are not analysed, so that they can be used as facets and filters
+		taxonomy := bleve.NewDocumentMapping()
+		taxonomy.DefaultAnalyzer = keywordAnalyzer.Name
+
+		node := bleve.NewDocumentMapping()
+		node.DefaultAnalyzer = a
+		node.AddFieldMappingsAt("title", text)
+		node.AddFieldMappingsAt("description", text)
+		node.AddFieldMappingsAt("content", text)
+		node.AddFieldMappingsAt("language", keyword)
+		node.AddFieldMappingsAt("template", keyword)
+		node.AddFieldMappingsAt("path", keyword)
+		node.AddSubDocumentMapping("properties", properties)
+		node.AddSubDocumentMapping("taxonomy", taxonomy)
+
+		t := "node"
+		if a != standard.Name {
+			t = "node_" + a
+		}
+		m.AddDocumentMapping(t, node)
+	}
+
+	m.DefaultType = "node"
+
+	return m
+}
+
+// openFTIndex return full-text index kept in memory, or on disk if dir is given. An index on disk that has been
+// built by onacms with another mapping is rebuilt from scratch; a directory that is neither empty nor an index built
+// by onacms is never touched. If the index is locked by another onacms (e.g. during a restart), an index in memory is
+// used instead.
+func openFTIndex(dir string, m mapping.IndexMapping) (bleve.Index, error) {
+	if dir == "" {
+		return bleve.NewMemOnly(m)
+	}
+
+	mb, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	mh := hash(mb)
+
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		if _, err := os.Stat(filepath.Join(dir, "index_meta.json")); err != nil {
+			return nil, fmt.Errorf("%s is not empty and not a search index, refusing to overwrite it", dir)
+		}
+
+		index, err := openFTIndexLocked(dir, ftIndexLockTimeout)
+		if errors.Is(err, errFTIndexLocked) {
+			log.Warn().Msg(fmt.Sprintf("search index in %s is locked by another onacms, keeping the index in memory", dir))
+			return bleve.NewMemOnly(m)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		h, err := index.GetInternal([]byte(mappingHashKey))
+		if err != nil || len(h) == 0 {
+			index.Close()
+			return nil, fmt.Errorf("%s is a search index not built by onacms, refusing to overwrite it", dir)
+		}
+		if string(h) == mh {
+			return index, nil
+		}
+		index.Close()
+
+		log.Info().Msg(fmt.Sprintf("search index in %s is outdated, rebuilding", dir))
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, err
+		}
+	} else if err == nil {
+		// bleve creates the directory itself
+		if err := os.Remove(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	index, err := bleve.New(dir, m)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := index.SetInternal([]byte(mappingHashKey), []byte(mh)); err != nil {
+		index.Close()
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// openFTIndexLocked open the index in dir, errFTIndexLocked if another process does not release its lock within
+// timeout. bleve waits for the lock forever, so an index opened after timeout is closed again.
+func openFTIndexLocked(dir string, timeout time.Duration) (bleve.Index, error) {
+	type opened struct {
+		index bleve.Index
+		err   error
+	}
+
+	ch := make(chan opened, 1)
+	go func() {
+		index, err := bleve.Open(dir)
+		ch <- opened{index, err}
+	}()
+
+	select {
+	case o := <-ch:
+		return o.index, o.err
+	case <-time.After(timeout):
+		go func() {
+			if o := <-ch; o.err == nil {
+				o.index.Close()
+			}
+		}()
+
+		return nil, errFTIndexLocked
+	}
+}
+
+// populateFTIndex add all enabled nodes to the full-text index, reindexing only nodes that have changed since
+// the index has been built (index on disk), and remove all others
+func (core *Core) populateFTIndex() {
+	log.Info().Msg("indexing Nodes ... ")
+
+	enabled := make(map[string]bool)
+	batch := core.ftindex.NewBatch()
+	n := 0
+
+	for _, node := range core.Nodes {
+		if !node.Enabled() {
+			continue
+		}
+
+		path := string(node.Path())
+		enabled[path] = true
+
+		ns := NewNodeSearchable(node, core.Site)
+		h := ns.hash()
+
+		if old, err := core.ftindex.GetInternal([]byte(nodeHashPrefix + path)); err == nil && string(old) == h {
+			// unchanged
+			continue
+		}
+
+		if err := batch.Index(path, ns); err != nil {
+			log.Warn().Msg(fmt.Sprintf("%s: %s", path, err.Error()))
+			continue
+		}
+		batch.SetInternal([]byte(nodeHashPrefix+path), []byte(h))
+		n++
+	}
+
+	// nodes that have been removed or disabled since the index has been built
+	dc, _ := core.ftindex.DocCount()
+	req := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), int(dc), 0, false)
+	if resp, err := core.ftindex.Search(req); err == nil {
+		for _, hit := range resp.Hits {
+			if !enabled[hit.ID] {
+				batch.Delete(hit.ID)
+				batch.DeleteInternal([]byte(nodeHashPrefix + hit.ID))
+			}
+		}
+	}
+
+	if err := core.ftindex.Batch(batch); err != nil {
+		log.Error().Msg(err.Error())
+		return
+	}
+
+	log.Info().Msg(fmt.Sprintf("%d node(s) (re)indexed", n))
+}
+
+// indexNode add node to the full-text index
+func (core *Core) indexNode(node *Node) {
+	path := string(node.Path())
+	ns := NewNodeSearchable(node, core.Site)
+
+	if err := core.ftindex.Index(path, ns); err != nil {
+		log.Warn().Msg(fmt.Sprintf("%s: %s", path, err.Error()))
+		return
+	}
+	core.ftindex.SetInternal([]byte(nodeHashPrefix+path), []byte(ns.hash()))
+}
+
+// unindexNode remove node from the full-text index
+func (core *Core) unindexNode(node *Node) {
+	path := string(node.Path())
+
+	core.ftindex.Delete(path)
+	core.ftindex.DeleteInternal([]byte(nodeHashPrefix + path))
+}
+
+// Close release the full-text index, e.g. the lock on an index on disk
+func (core *Core) Close() error {
+	if core.ftindex == nil {
+		return nil
+	}
+
+	return core.ftindex.Close()
+}
+
+// hash return content hash of the document indexed for a node
+func (ns *NodeSearchable) hash() string {
+	b, _ := json.Marshal(ns)
+	return hash(b)
+}
+
+func hash(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
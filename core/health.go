@@ -0,0 +1,78 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Status status of a loaded Core, e.g. for readiness probes
+type Status struct {
+	Ready   bool   `json:"ready"`
+	Version string `json:"version"`
+	Nodes   int    `json:"nodes"`
+	Search  string `json:"search"`
+}
+
+// Status return status of core: ready once nodes are loaded and the search index is built (unless search is
+// disabled), not ready if search is enabled but the index could not be built
+func (core *Core) Status() Status {
+	s := Status{
+		Ready:   len(core.Nodes) > 0,
+		Version: core.version,
+		Nodes:   len(core.Nodes),
+		Search:  "disabled",
+	}
+
+	if core.ftindex != nil {
+		s.Search = "ready"
+	} else if core.Site.Search() {
+		s.Search = "error"
+		s.Ready = false
+	}
+
+	return s
+}
+
+// Version return site version (from: 'site.xml' 'version'), a hash of the loaded content if not set
+func (core *Core) Version() string {
+	return core.version
+}
+
+// contentHash return hash of site configuration, public files, templates and nodes as loaded
+func (core *Core) contentHash() string {
+	h := sha256.New()
+
+	// JSON rather than %v: pointers (e.g. forms) must not end up in the hash
+	e := json.NewEncoder(h)
+
+	e.Encode(core.Site.xmlSite)
+
+	var keys []string
+	for k := range core.PublicFiles {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s %s\n", k, core.PublicFiles[k].ETag)
+	}
+
+	keys = nil
+	for k := range core.Templates {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s %s\n", k, core.Templates[k].Content())
+		e.Encode(core.Templates[k].xmlTemplate)
+	}
+
+	for _, n := range core.Nodes {
+		fmt.Fprintf(h, "%s %s\n", n.Path(), n.Content())
+		e.Encode(n.xmlNode)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
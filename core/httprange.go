@@ -0,0 +1,193 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errRangeNotSatisfiable none of the requested ranges overlaps the content (-> HTTP 416)
+var errRangeNotSatisfiable = errors.New("requested range not satisfiable")
+
+// errRangeInvalid the Range header is syntactically invalid and has to be ignored
+var errRangeInvalid = errors.New("invalid range")
+
+// httpRange byte range of a partial response
+// see https://tools.ietf.org/html/rfc7233#section-2.1
+type httpRange struct {
+	start  int64
+	length int64
+}
+
+// contentRange return value of the Content-Range header for range r of content with size bytes
+func (r httpRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+// parseRange parse the value of an HTTP Range header for content with size bytes, dropping all ranges
+// that cannot be satisfied
+func parseRange(s string, size int64) ([]httpRange, error) {
+	const b = "bytes="
+
+	if !strings.HasPrefix(s, b) {
+		// we only understand byte ranges
+		return nil, errRangeInvalid
+	}
+
+	var ranges []httpRange
+	specs := 0
+
+	for _, spec := range strings.Split(s[len(b):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		specs++
+
+		i := strings.Index(spec, "-")
+		if i < 0 {
+			return nil, errRangeInvalid
+		}
+
+		first, last := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+
+		var r httpRange
+
+		if first == "" {
+			// suffix-byte-range-spec, e.g. "-500" (= the last 500 bytes)
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, errRangeInvalid
+			}
+
+			if n == 0 || size == 0 {
+				continue
+			}
+
+			if n > size {
+				n = size
+			}
+
+			r.start = size - n
+			r.length = n
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, errRangeInvalid
+			}
+
+			end := size - 1
+			if last != "" {
+				end, err = strconv.ParseInt(last, 10, 64)
+				if err != nil || end < start {
+					return nil, errRangeInvalid
+				}
+			}
+
+			if start >= size {
+				continue
+			}
+
+			if end >= size {
+				end = size - 1
+			}
+
+			r.start = start
+			r.length = end - start + 1
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	if specs == 0 {
+		return nil, errRangeInvalid
+	}
+
+	if len(ranges) == 0 {
+		return nil, errRangeNotSatisfiable
+	}
+
+	return ranges, nil
+}
+
+// ifRange return if the validator in the If-Range header (if any) matches the current representation,
+// i.e. if a partial response may be sent
+// see https://tools.ietf.org/html/rfc7233#section-3.2
+func ifRange(r *http.Request, etag string, lastModified time.Time) bool {
+	ir := strings.TrimSpace(r.Header.Get("If-Range"))
+	if ir == "" {
+		return true
+	}
+
+	if d, ok := parseHTTPDate(ir); ok {
+		// the date has to be an exact match
+		return !lastModified.IsZero() && lastModified.Truncate(time.Second).Equal(d)
+	}
+
+	// If-Range requires the strong comparison function, weak entity tags never match
+	return etagMatch(ir, etag, false) && ir != "*"
+}
+
+// serveRanges write a partial response (HTTP 206) for the ranges requested in r, return false if the
+// Range header has to be ignored and the full content is to be sent instead
+func serveRanges(w http.ResponseWriter, r *http.Request, content []byte, contentType string) bool {
+	size := int64(len(content))
+
+	ranges, err := parseRange(r.Header.Get("Range"), size)
+	if err == errRangeNotSatisfiable {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		http.Error(w, "requested range not satisfiable", 416)
+		return true
+	}
+	if err != nil {
+		return false
+	}
+
+	var sum int64
+	for _, rng := range ranges {
+		sum += rng.length
+	}
+	if sum > size {
+		// the client asks for more than the whole content (e.g. many overlapping ranges),
+		// so we rather send everything once
+		return false
+	}
+
+	if len(ranges) == 1 {
+		rng := ranges[0]
+
+		w.Header().Set("Content-Range", rng.contentRange(size))
+		w.Header().Set("Content-Length", strconv.FormatInt(rng.length, 10))
+		w.WriteHeader(206)
+		w.Write(content[rng.start : rng.start+rng.length])
+
+		return true
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, rng := range ranges {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":  {contentType},
+			"Content-Range": {rng.contentRange(size)},
+		})
+		if err != nil {
+			return false
+		}
+		pw.Write(content[rng.start : rng.start+rng.length])
+	}
+	mw.Close()
+
+	w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	w.WriteHeader(206)
+	w.Write(body.Bytes())
+
+	return true
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header string
+		size   int64
+		ranges []httpRange
+		err    error
+	}{
+		{"bytes=0-499", 1000, []httpRange{{0, 500}}, nil},
+		{"bytes=500-", 1000, []httpRange{{500, 500}}, nil},
+		{"bytes=900-2000", 1000, []httpRange{{900, 100}}, nil},
+		{"bytes=-500", 1000, []httpRange{{500, 500}}, nil},
+		{"bytes=-2000", 1000, []httpRange{{0, 1000}}, nil},
+		{"bytes= 0-9 , -10", 1000, []httpRange{{0, 10}, {990, 10}}, nil},
+		{"bytes=0-99,50-149", 1000, []httpRange{{0, 100}, {50, 100}}, nil},
+		{"bytes=0-9,1000-", 1000, []httpRange{{0, 10}}, nil},
+		{"bytes=1000-", 1000, nil, errRangeNotSatisfiable},
+		{"bytes=-0", 1000, nil, errRangeNotSatisfiable},
+		{"bytes=-5", 0, nil, errRangeNotSatisfiable},
+		{"bytes=0-", 0, nil, errRangeNotSatisfiable},
+		{"items=0-9", 1000, nil, errRangeInvalid},
+		{"bytes=", 1000, nil, errRangeInvalid},
+		{"bytes=9-0", 1000, nil, errRangeInvalid},
+		{"bytes=abc", 1000, nil, errRangeInvalid},
+		{"bytes=0-x", 1000, nil, errRangeInvalid},
+		{"bytes=--5", 1000, nil, errRangeInvalid},
+	}
+
+	for _, test := range tests {
+		ranges, err := parseRange(test.header, test.size)
+		if err != test.err || !reflect.DeepEqual(ranges, test.ranges) {
+			t.Errorf("parseRange(%q, %d) = %v, %v; want %v, %v", test.header, test.size, ranges, err, test.ranges, test.err)
+		}
+	}
+}
+
+func TestServeRanges(t *testing.T) {
+	content := []byte("0123456789")
+
+	tests := []struct {
+		header       string
+		served       bool
+		status       int
+		contentRange string
+		body         string
+	}{
+		{"bytes=2-4", true, 206, "bytes 2-4/10", "234"},
+		{"bytes=-3", true, 206, "bytes 7-9/10", "789"},
+		{"bytes=8-20", true, 206, "bytes 8-9/10", "89"},
+		{"bytes=10-", true, 416, "bytes */10", ""},
+		{"bytes=0-5,3-9", false, 0, "", ""},
+		{"bytes=x", false, 0, "", ""},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/file", nil)
+		r.Header.Set("Range", test.header)
+		w := httptest.NewRecorder()
+
+		served := serveRanges(w, r, content, "text/plain")
+		if served != test.served {
+			t.Errorf("%s: served = %v, want %v", test.header, served, test.served)
+			continue
+		}
+		if !served {
+			continue
+		}
+
+		if w.Code != test.status {
+			t.Errorf("%s: status = %d, want %d", test.header, w.Code, test.status)
+		}
+		if cr := w.Header().Get("Content-Range"); cr != test.contentRange {
+			t.Errorf("%s: Content-Range = %q, want %q", test.header, cr, test.contentRange)
+		}
+		if test.status == 206 && w.Body.String() != test.body {
+			t.Errorf("%s: body = %q, want %q", test.header, w.Body.String(), test.body)
+		}
+	}
+}
+
+func TestServeRangesMultipart(t *testing.T) {
+	r := httptest.NewRequest("GET", "/file", nil)
+	r.Header.Set("Range", "bytes=0-1,-2")
+	w := httptest.NewRecorder()
+
+	if !serveRanges(w, r, []byte("0123456789"), "text/plain") {
+		t.Fatal("served = false, want true")
+	}
+
+	if w.Code != 206 {
+		t.Errorf("status = %d, want 206", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "multipart/byteranges; boundary=") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	for _, s := range []string{"Content-Range: bytes 0-1/10", "Content-Range: bytes 8-9/10", "\r\n\r\n01\r\n", "\r\n\r\n89\r\n"} {
+		if !strings.Contains(w.Body.String(), s) {
+			t.Errorf("body does not contain %q", s)
+		}
+	}
+}
+
+func TestIfRange(t *testing.T) {
+	lastModified := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	etag := `"abc"`
+
+	tests := []struct {
+		header       string
+		lastModified time.Time
+		want         bool
+	}{
+		{"", lastModified, true},
+		{`"abc"`, lastModified, true},
+		{`"xyz"`, lastModified, false},
+		{`W/"abc"`, lastModified, false},
+		{"*", lastModified, false},
+		{"Sun, 18 Oct 2026 10:00:00 GMT", lastModified, true},
+		{"Sun, 18 Oct 2026 10:00:00 GMT", lastModified.Add(500 * time.Millisecond), true},
+		{"Sun, 18 Oct 2026 09:59:59 GMT", lastModified, false},
+		{"Sun, 18 Oct 2026 10:00:01 GMT", lastModified, false},
+		{"Sun, 18 Oct 2026 10:00:00 GMT", time.Time{}, false},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/file", nil)
+		if test.header != "" {
+			r.Header.Set("If-Range", test.header)
+		}
+
+		if got := ifRange(r, etag, test.lastModified); got != test.want {
+			t.Errorf("ifRange(%q, %v) = %v, want %v", test.header, test.lastModified, got, test.want)
+		}
+	}
+}
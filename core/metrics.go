@@ -0,0 +1,70 @@
+package core
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Prometheus metrics, exposed only if a metrics port is given (see helpers.MetricsHandler)
+var (
+	renderErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "onacms_template_render_errors_total",
+		Help: "Number of nodes that failed to render through their template chain.",
+	})
+
+	minifyErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "onacms_minify_errors_total",
+		Help: "Number of rendered nodes that failed to minify (sent unminified).",
+	})
+
+	searchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "onacms_search_duration_seconds",
+		Help:    "Latency of full-text search queries.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 12),
+	})
+
+	contentItems = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "onacms_content_items",
+		Help: "Number of nodes, templates and public files loaded.",
+	}, []string{"kind"})
+
+	publicFilesBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "onacms_public_files_bytes",
+		Help: "Memory held by public files, including precompressed variants.",
+	})
+
+	loads = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "onacms_loads_total",
+		Help: "Number of times the site has been loaded from disk.",
+	})
+
+	loadDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "onacms_load_duration_seconds",
+		Help:    "Time spent loading the site from disk, including the search index.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+)
+
+func init() {
+	prometheus.MustRegister(renderErrors, minifyErrors, searchDuration, contentItems, publicFilesBytes, loads, loadDuration)
+}
+
+// observeLoad update the content metrics after the site has been loaded
+func (core *Core) observeLoad(start time.Time) {
+	loads.Inc()
+	loadDuration.Observe(time.Since(start).Seconds())
+
+	contentItems.WithLabelValues("nodes").Set(float64(len(core.Nodes)))
+	contentItems.WithLabelValues("templates").Set(float64(len(core.Templates)))
+	contentItems.WithLabelValues("public-files").Set(float64(len(core.PublicFiles)))
+
+	var b int
+	for _, f := range core.PublicFiles {
+		b += len(f.Content)
+		for _, e := range f.Encoded {
+			b += len(e)
+		}
+	}
+	publicFilesBytes.Set(float64(b))
+}
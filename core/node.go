@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang-commonmark/markdown"
 )
@@ -31,10 +32,14 @@ type XMLNode struct {
 	Template            string        `xml:"template"`
 	Navigable           string        `xml:"navigable"`
 	Enabled             string        `xml:"enabled"`
+	PublishAt           string        `xml:"publish-at"`
+	ExpireAt            string        `xml:"expire-at"`
 	Content             string        `xml:"content"`
 	ContentFile         string        `xml:"content-file"`
 	RedirectTo          string        `xml:"redirect-to"`
+	CacheControl        string        `xml:"cache-control"`
 	ApplicationEndpoint string        `xml:"application-endpoint"`
+	Form                *XMLForm      `xml:"form"`
 	Property            []XMLProperty `xml:"property"`
 }
 
@@ -155,12 +160,16 @@ func (n *Node) Navigable() bool {
 	return false
 }
 
-// Enabled return if node is enabled (from: 'enabled')
+// Enabled return if node is enabled (from: 'enabled') and published at the current time (from: 'publish-at', 'expire-at')
 func (n *Node) Enabled() bool {
+	return n.enabled() && n.Published(time.Now())
+}
+
+func (n *Node) enabled() bool {
 	enabled := strings.ToLower(strings.TrimSpace(n.xmlNode.Enabled))
 
 	if enabled == "" && n.Parent() != nil {
-		return n.Parent().Enabled()
+		return n.Parent().enabled()
 	}
 
 	if enabled == "1" || enabled == "on" || strings.HasPrefix(enabled, "enable") || enabled == "true" {
@@ -170,6 +179,50 @@ func (n *Node) Enabled() bool {
 	return false
 }
 
+// PublishAt return time the node gets published (from: 'publish-at'), zero time if not set
+func (n *Node) PublishAt() time.Time {
+	t, _ := n.publishAt()
+	return t
+}
+
+func (n *Node) publishAt() (time.Time, error) {
+	if strings.TrimSpace(n.xmlNode.PublishAt) == "" && n.Parent() != nil {
+		return n.Parent().publishAt()
+	}
+
+	return parseTimestamp(n.xmlNode.PublishAt)
+}
+
+// ExpireAt return time the node expires (from: 'expire-at'), zero time if not set
+func (n *Node) ExpireAt() time.Time {
+	t, _ := n.expireAt()
+	return t
+}
+
+func (n *Node) expireAt() (time.Time, error) {
+	if strings.TrimSpace(n.xmlNode.ExpireAt) == "" && n.Parent() != nil {
+		return n.Parent().expireAt()
+	}
+
+	return parseTimestamp(n.xmlNode.ExpireAt)
+}
+
+// Published return if t is within the publishing period of the node (from: 'publish-at', 'expire-at')
+func (n *Node) Published(t time.Time) bool {
+	p, err := n.publishAt()
+	if err != nil || t.Before(p) {
+		// an invalid 'publish-at' must never publish embargoed content
+		return false
+	}
+
+	e, err := n.expireAt()
+	if err != nil || (!e.IsZero() && !t.Before(e)) {
+		return false
+	}
+
+	return true
+}
+
 // Content return node content (from: 'content')
 func (n *Node) Content() string {
 	return n.xmlNode.Content
@@ -264,6 +317,24 @@ func (n *Node) RedirectTo() string {
 	return strings.TrimSpace(n.xmlNode.RedirectTo)
 }
 
+// CacheControl return value of the Cache-Control header for this node (from: 'cache-control')
+func (n *Node) CacheControl() string {
+	cc := strings.TrimSpace(n.xmlNode.CacheControl)
+	if cc == "" && n.Parent() != nil {
+		cc = n.Parent().CacheControl()
+	}
+	return cc
+}
+
+// Form return form endpoint of node, nil if there is none (from: 'form')
+func (n *Node) Form() *Form {
+	if n.xmlNode.Form == nil {
+		return nil
+	}
+
+	return &Form{xmlForm: n.xmlNode.Form}
+}
+
 // ApplicationEndpoint return if node is an application endpoint (from: 'application-endpoint')
 func (n *Node) ApplicationEndpoint() bool {
 	appep := strings.ToLower(strings.TrimSpace(n.xmlNode.ApplicationEndpoint))
@@ -274,3 +345,22 @@ func (n *Node) ApplicationEndpoint() bool {
 
 	return false
 }
+
+// parseTimestamp parse either a unix timestamp (like 'created' and 'lastmodified') or an RFC 3339 date/time,
+// return zero time for an empty string
+func parseTimestamp(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(i, 0), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	return time.Parse("2006-01-02", s)
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"sort"
 	"strings"
 )
 
@@ -21,6 +22,24 @@ func FindNode(path string, nodes []*Node) *Node {
 	return nil
 }
 
+// FindDraftNode searches for an exact match for path in the array of nodes, no matter if the node is enabled
+// and published or not (see draft preview mode), return nil if there is none.
+func FindDraftNode(path string, nodes []*Node) *Node {
+	path = strings.TrimSpace(strings.ToLower(path))
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	for _, node := range nodes {
+		if string(node.Path()) == path {
+			return node
+		}
+	}
+
+	return nil
+}
+
 /*
 FindApplicationEndpointNode search for the best match for a given path (right to left)
 where application-endpoint is set as property of that node, return nil if there is none.
@@ -84,6 +103,23 @@ func RootNodes(nodes []*Node) []*Node {
 	return rootNodes
 }
 
+// Languages return languages of all enabled nodes, sorted, "" if there are enabled nodes without language
+func Languages(nodes []*Node) []string {
+	var languages []string
+	seen := make(map[string]bool)
+
+	for _, n := range nodes {
+		if l := n.Language(); n.Enabled() && !seen[l] {
+			seen[l] = true
+			languages = append(languages, l)
+		}
+	}
+
+	sort.Strings(languages)
+
+	return languages
+}
+
 // SiblingsAndSelf return all nodes at the same hierarchie including own node
 func SiblingsAndSelf(node *Node, nodes []*Node) []*Node {
 	if node.Parent() != nil {
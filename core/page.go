@@ -0,0 +1,60 @@
+package core
+
+import (
+	"time"
+)
+
+// renderedPage node rendered through its template chain and minified, ready to be sent
+type renderedPage struct {
+	Content      []byte
+	MimeType     string
+	ETag         string
+	LastModified time.Time
+	Encoded      map[string][]byte
+}
+
+// cachedPage return rendered page for node from the page cache, nil if there is none
+func (core *Core) cachedPage(node *Node) *renderedPage {
+	core.pagesMutex.RLock()
+	defer core.pagesMutex.RUnlock()
+
+	return core.pages[string(node.Path())]
+}
+
+// cachePage add rendered page for node to the page cache
+func (core *Core) cachePage(node *Node, page *renderedPage) {
+	core.pagesMutex.Lock()
+	defer core.pagesMutex.Unlock()
+
+	core.pages[string(node.Path())] = page
+}
+
+// flushPages empty the page cache, e.g. when nodes get published or expire
+func (core *Core) flushPages() {
+	core.pagesMutex.Lock()
+	defer core.pagesMutex.Unlock()
+
+	core.pages = make(map[string]*renderedPage)
+}
+
+// cacheable return if the rendered node may be kept in the page cache
+func (core *Core) cacheable(node *Node) bool {
+	if !node.Enabled() || node.ApplicationEndpoint() || node.Form() != nil {
+		return false
+	}
+
+	t := core.nodeTemplate(node)
+	for t != nil {
+		if !t.Cacheable() {
+			return false
+		}
+
+		if t.Parent() == "" {
+			break
+		}
+
+		t = core.Templates[t.Parent()]
+	}
+
+	return t != nil
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// PreviewCookie name of the cookie carrying the preview token
+	PreviewCookie = "onacms-preview"
+
+	// PreviewParameter name of the query parameter carrying the preview token
+	PreviewParameter = "preview"
+)
+
+// Preview draft preview mode, unlocked by a token signed with a secret configured at startup
+type Preview struct {
+	secret []byte
+}
+
+// NewPreview initialiser, return nil (= preview mode disabled) if secret is empty
+func NewPreview(secret string) *Preview {
+	if secret == "" {
+		return nil
+	}
+
+	return &Preview{secret: []byte(secret)}
+}
+
+// Token return a new preview token valid until expires
+func (p *Preview) Token(expires time.Time) string {
+	e := strconv.FormatInt(expires.Unix(), 10)
+
+	return e + "." + p.sign(e)
+}
+
+// Valid return if token has been signed with our secret and has not yet expired
+func (p *Preview) Valid(token string) bool {
+	t := strings.SplitN(strings.TrimSpace(token), ".", 2)
+	if len(t) != 2 {
+		return false
+	}
+
+	if !hmac.Equal([]byte(t[1]), []byte(p.sign(t[0]))) {
+		return false
+	}
+
+	e, err := strconv.ParseInt(t[0], 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return time.Now().Before(time.Unix(e, 0))
+}
+
+// Authorised return if request r unlocks preview mode, either by query parameter or by cookie.
+// A valid token passed as query parameter is stored in a cookie, so that links within the site
+// stay in preview mode.
+func (p *Preview) Authorised(w http.ResponseWriter, r *http.Request) bool {
+	if p == nil {
+		return false
+	}
+
+	if token := r.URL.Query().Get(PreviewParameter); token != "" && p.Valid(token) {
+		e, _ := strconv.ParseInt(strings.SplitN(token, ".", 2)[0], 10, 64)
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     PreviewCookie,
+			Value:    token,
+			Path:     "/",
+			Expires:  time.Unix(e, 0),
+			Secure:   r.TLS != nil,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		return true
+	}
+
+	c, err := r.Cookie(PreviewCookie)
+
+	return err == nil && p.Valid(c.Value)
+}
+
+func (p *Preview) sign(s string) string {
+	h := hmac.New(sha256.New, p.secret)
+	h.Write([]byte(s))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+var bodyTag = regexp.MustCompile(`(?i)<body[^>]*>`)
+
+// draftBanner insert a visible "draft" banner right after the opening <body> tag of an HTML page
+func draftBanner(page string) string {
+	const banner = `<div style="position:sticky;top:0;z-index:2147483647;padding:.5em;background:#c00;color:#fff;font:bold 1em sans-serif;text-align:center">DRAFT &ndash; not visible to the public</div>`
+
+	loc := bodyTag.FindStringIndex(page)
+	if loc == nil {
+		return banner + page
+	}
+
+	return page[:loc[1]] + banner + page[loc[1]:]
+}
@@ -1,7 +1,12 @@
 package core
 
+import "time"
+
 // PublicFile struct
 type PublicFile struct {
 	MimeType string
 	Content  []byte
+	ModTime  time.Time
+	ETag     string
+	Encoded  map[string][]byte
 }
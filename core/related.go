@@ -0,0 +1,160 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+const (
+	// relatedTerms number of the most frequent terms of a node that similar nodes are searched for
+	relatedTerms = 25
+
+	// relatedMax maximum number of related nodes per node
+	relatedMax = 20
+)
+
+// Relations similar nodes by node, computed from the full-text index on first use and kept until the site is
+// loaded again
+type Relations struct {
+	index   bleve.Index
+	nodes   []*Node
+	site    *Site
+	related map[*Node][]*Node
+	mutex   sync.Mutex
+}
+
+// NewRelations initialiser, nil if there is no full-text index
+func NewRelations(index bleve.Index, nodes []*Node, site *Site) *Relations {
+	if index == nil {
+		return nil
+	}
+
+	return &Relations{index: index, nodes: nodes, site: site, related: make(map[*Node][]*Node)}
+}
+
+// Related return at most n (up to 20) enabled nodes in the language of node that are most similar to it: nodes that
+// share its most frequent terms or the values of taxonomy properties (see Site.SearchFacets)
+func (rel *Relations) Related(node *Node, n int) []*Node {
+	if rel == nil || node == nil || n <= 0 {
+		return nil
+	}
+
+	rel.mutex.Lock()
+	related, ok := rel.related[node]
+	if !ok {
+		var err error
+		if related, err = rel.find(node); err != nil {
+			log.Warn().Msg(fmt.Sprintf("%s: related nodes: %s", node.Path(), err.Error()))
+		}
+		rel.related[node] = related
+	}
+	rel.mutex.Unlock()
+
+	var nodes []*Node
+	for _, r := range related {
+		if len(nodes) == n {
+			break
+		}
+
+		// may have expired since
+		if r.Enabled() {
+			nodes = append(nodes, r)
+		}
+	}
+
+	return nodes
+}
+
+// find search the full-text index for nodes similar to node
+func (rel *Relations) find(node *Node) ([]*Node, error) {
+	ns := NewNodeSearchable(node, rel.site)
+	language := strings.ToLower(ns.Language)
+
+	terms := bleve.NewDisjunctionQuery()
+
+	// most frequent terms of title, description and content, analysed like the index does for the language
+	if a := rel.index.Mapping().AnalyzerNamed(languageAnalyzer(language)); a != nil {
+		tf := make(map[string]int)
+		for _, t := range a.Analyze([]byte(strings.Join([]string{ns.Title, ns.Title, ns.Description, ns.Content}, " "))) {
+			if len(t.Term) > 2 {
+				tf[string(t.Term)]++
+			}
+		}
+
+		var top []string
+		for t := range tf {
+			top = append(top, t)
+		}
+		sort.Slice(top, func(i, j int) bool {
+			if tf[top[i]] != tf[top[j]] {
+				return tf[top[i]] > tf[top[j]]
+			}
+			return top[i] < top[j]
+		})
+		if len(top) > relatedTerms {
+			top = top[:relatedTerms]
+		}
+
+		for _, t := range top {
+			for _, field := range []string{"title", "content"} {
+				tq := bleve.NewTermQuery(t)
+				tq.SetField(field)
+				terms.AddQuery(tq)
+			}
+		}
+	}
+
+	// shared taxonomy values weigh more than shared words
+	for p, values := range ns.Taxonomy {
+		for _, v := range values {
+			tq := bleve.NewTermQuery(v)
+			tq.SetField("taxonomy." + p)
+			tq.SetBoost(3)
+			terms.AddQuery(tq)
+		}
+	}
+
+	if len(terms.Disjuncts) == 0 {
+		return nil, nil
+	}
+
+	q := bleve.NewBooleanQuery()
+	q.AddMust(terms)
+	q.AddMustNot(bleve.NewDocIDQuery([]string{string(node.Path())}))
+	if language != "" {
+		tq := bleve.NewTermQuery(language)
+		tq.SetField("language")
+		q.AddMust(tq)
+	} else {
+		// nodes without language
+		var known []query.Query
+		for _, l := range Languages(rel.nodes) {
+			if l == "" {
+				continue
+			}
+			tq := bleve.NewTermQuery(l)
+			tq.SetField("language")
+			known = append(known, tq)
+		}
+		q.AddMustNot(known...)
+	}
+
+	resp, err := rel.index.Search(bleve.NewSearchRequestOptions(q, relatedMax, 0, false))
+	if err != nil {
+		return nil, err
+	}
+
+	var related []*Node
+	for _, hit := range resp.Hits {
+		if r := FindNode(hit.ID, rel.nodes); r != nil {
+			related = append(related, r)
+		}
+	}
+
+	return related, nil
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"net/http"
+)
+
+// Resolutions, i.e. how Core.HTTP has answered a request
+const (
+	ResolutionPublicFile       = "public-file"
+	ResolutionNode             = "node"
+	ResolutionEndpoint         = "endpoint"
+	ResolutionForm             = "form"
+	ResolutionSearch           = "search"
+	ResolutionRedirect         = "redirect"
+	ResolutionFallbackRedirect = "fallback-redirect"
+	ResolutionLanguageRedirect = "language-redirect"
+	ResolutionNotFound         = "not-found"
+)
+
+// Page cache results for rendered nodes
+const (
+	CacheHit    = "hit"
+	CacheMiss   = "miss"
+	CacheBypass = "bypass"
+)
+
+// RequestInfo details on how Core.HTTP has answered a request, e.g. for access logs or metrics
+type RequestInfo struct {
+	Resolution string
+	Target     string
+	Cache      string
+}
+
+type requestInfoKey struct{}
+
+// WithRequestInfo return a shallow copy of r carrying a RequestInfo that is filled in by Core.HTTP
+func WithRequestInfo(r *http.Request) (*http.Request, *RequestInfo) {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*RequestInfo); ok {
+		// already there (e.g. access log and metrics)
+		return r, info
+	}
+
+	info := &RequestInfo{}
+
+	return r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)), info
+}
+
+// WithNewRequestInfo return a shallow copy of r carrying a new RequestInfo instead of the one r may carry, e.g. for
+// a handler that may still be running when the response has been sent already
+func WithNewRequestInfo(r *http.Request) (*http.Request, *RequestInfo) {
+	info := &RequestInfo{}
+
+	return r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)), info
+}
+
+// requestInfo return RequestInfo of r, a throwaway one if there is none
+func requestInfo(r *http.Request) *RequestInfo {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*RequestInfo); ok {
+		return info
+	}
+
+	return &RequestInfo{}
+}
@@ -1,27 +1,153 @@
 package core
 
 import (
+	"fmt"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
 	"golang.org/x/net/html"
 )
 
-// NodeSearchable struct
+// Search modes, i.e. how the term of a search is interpreted
+const (
+	// SearchMatch words of the term analysed like the content, '*' and '?' are wildcards (default)
+	SearchMatch = "match"
+
+	// SearchPhrase all words of the term in the same order
+	SearchPhrase = "phrase"
+
+	// SearchFuzzy words of the term, allowing for typos (edit distance 1, 2 for long words)
+	SearchFuzzy = "fuzzy"
+
+	// SearchPrefix words of the term, the last one as prefix (e.g. 'threat intel')
+	SearchPrefix = "prefix"
+
+	// SearchQueryString term in the bleve query string syntax, e.g. '+title:threat -content:phishing'
+	// (see http://blevesearch.com/docs/Query-String-Query/)
+	SearchQueryString = "query"
+)
+
+// NodeSearchable document added to the full-text index for a node
 type NodeSearchable struct {
-	Content string
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	Content     string              `json:"content"`
+	Language    string              `json:"language"`
+	Template    string              `json:"template"`
+	Path        []string            `json:"path"`
+	Properties  map[string]string   `json:"properties,omitempty"`
+	Taxonomy    map[string][]string `json:"taxonomy,omitempty"`
+}
+
+// Type return document type, used by bleve to choose the document mapping (and so the analyser) for the language
+func (ns *NodeSearchable) Type() string {
+	return documentType(ns.Language)
 }
 
 // SearchResult struct
 type SearchResult struct {
-	Index   int
-	URL     string
-	Score   string
-	Content string
+	Index       int
+	URL         string
+	Score       string
+	Title       string
+	Description string
+	Language    string
+	Content     string
+	Fragments   []string
+	score       float64
+}
+
+// SearchResults page of results of a search
+type SearchResults struct {
+	Total   uint64
+	Took    time.Duration
+	Offset  int
+	Limit   int
+	Results []SearchResult
+	Facets  []SearchFacet
+}
+
+// HasMore return if there are results after this page
+func (results *SearchResults) HasMore() bool {
+	return uint64(results.Offset+len(results.Results)) < results.Total
+}
+
+// Facet return facet by name, nil if it has not been requested
+func (results *SearchResults) Facet(name string) *SearchFacet {
+	for i := range results.Facets {
+		if results.Facets[i].Name == name {
+			return &results.Facets[i]
+		}
+	}
+
+	return nil
+}
+
+// SearchFacet number of results by value of a field, e.g. by language
+type SearchFacet struct {
+	Name    string
+	Terms   []SearchFacetTerm
+	Missing int
+	Other   int
+}
+
+// SearchFacetTerm value of a facet and number of results with that value
+type SearchFacetTerm struct {
+	Term  string
+	Count int
+}
+
+// searchRequest search in the full-text index, Language "" for all languages
+type searchRequest struct {
+	Term      string
+	Mode      string
+	Language  string
+	Offset    int
+	Limit     int
+	Facets    []string
+	FacetSize int
+	Filters   map[string]string
 }
 
-// NewNodeSearchable initialiser
-func NewNodeSearchable(node *Node) *NodeSearchable {
-	nodeSearchable := &NodeSearchable{}
+// NewNodeSearchable initialiser, custom properties of the node are indexed as configured for site (see
+// Site.SearchProperties and Site.SearchFacets)
+func NewNodeSearchable(node *Node, site *Site) *NodeSearchable {
+	nodeSearchable := &NodeSearchable{
+		Title:       node.Title(),
+		Description: node.Description(),
+		Language:    node.Language(),
+		Template:    node.Template(),
+	}
+
+	for _, s := range strings.Split(strings.Trim(string(node.Path()), "/"), "/") {
+		if s != "" {
+			nodeSearchable.Path = append(nodeSearchable.Path, s)
+		}
+	}
+
+	for _, p := range site.SearchProperties() {
+		if v := node.CustomProperty(p, false); v != "" {
+			if nodeSearchable.Properties == nil {
+				nodeSearchable.Properties = make(map[string]string)
+			}
+			nodeSearchable.Properties[p] = v
+		}
+	}
+
+	// taxonomy properties, e.g. 'category' or 'tags', are comma separated lists of values
+	for _, p := range site.SearchFacets() {
+		for _, v := range strings.Split(node.CustomProperty(p, false), ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				if nodeSearchable.Taxonomy == nil {
+					nodeSearchable.Taxonomy = make(map[string][]string)
+				}
+				nodeSearchable.Taxonomy[p] = append(nodeSearchable.Taxonomy[p], v)
+			}
+		}
+	}
 
 	doc, err := html.Parse(strings.NewReader(string(node.Render())))
 	if err == nil {
@@ -43,3 +169,298 @@ func NewNodeSearchable(node *Node) *NodeSearchable {
 
 	return nodeSearchable
 }
+
+// hitField return stored field of a search hit as string
+func hitField(fields map[string]interface{}, name string) string {
+	s, _ := fields[name].(string)
+	return s
+}
+
+// searchQuery return query for term in languages ("" for nodes without language), matching the fields in boost with
+// the analyser of the language, so that e.g. stemming works the same way as when the nodes have been indexed
+func searchQuery(term string, mode string, languages []string, boost map[string]float64) (query.Query, error) {
+	var known []query.Query
+	for _, l := range languages {
+		if l != "" {
+			tq := bleve.NewTermQuery(l)
+			tq.SetField("language")
+			known = append(known, tq)
+		}
+	}
+
+	q := bleve.NewDisjunctionQuery()
+
+	for _, l := range languages {
+		var fields query.Query
+		if mode == SearchQueryString {
+			// fields, boosts etc. are part of the query string
+			qs, err := bleve.NewQueryStringQuery(term).Parse()
+			if err != nil {
+				return nil, err
+			}
+			setAnalyzer(qs, languageAnalyzer(l))
+			fields = qs
+		} else {
+			// matches in e.g. the title rank higher than matches in the content
+			d := bleve.NewDisjunctionQuery()
+			for field, b := range boost {
+				if fq := fieldQuery(term, mode, field, languageAnalyzer(l), b); fq != nil {
+					d.AddQuery(fq)
+				}
+			}
+			fields = d
+		}
+
+		bq := bleve.NewBooleanQuery()
+		bq.AddMust(fields)
+		if l != "" {
+			tq := bleve.NewTermQuery(l)
+			tq.SetField("language")
+			bq.AddMust(tq)
+		} else {
+			// nodes without language
+			bq.AddMustNot(known...)
+		}
+
+		q.AddQuery(bq)
+	}
+
+	return q, nil
+}
+
+// setAnalyzer set analyser of all match queries in q (e.g. a parsed query string) that do not have one
+func setAnalyzer(q query.Query, analyzer string) {
+	switch q := q.(type) {
+	case *query.MatchQuery:
+		if q.Analyzer == "" {
+			q.Analyzer = analyzer
+		}
+	case *query.MatchPhraseQuery:
+		if q.Analyzer == "" {
+			q.Analyzer = analyzer
+		}
+	case *query.BooleanQuery:
+		for _, c := range []query.Query{q.Must, q.Should, q.MustNot} {
+			if c != nil {
+				setAnalyzer(c, analyzer)
+			}
+		}
+	case *query.ConjunctionQuery:
+		for _, c := range q.Conjuncts {
+			setAnalyzer(c, analyzer)
+		}
+	case *query.DisjunctionQuery:
+		for _, c := range q.Disjuncts {
+			setAnalyzer(c, analyzer)
+		}
+	}
+}
+
+// fieldQuery return query for term in field according to mode, nil if there is nothing to search for
+func fieldQuery(term string, mode string, field string, analyzer string, boost float64) query.Query {
+	switch mode {
+	case SearchPhrase:
+		pq := bleve.NewMatchPhraseQuery(term)
+		pq.SetField(field)
+		pq.SetBoost(boost)
+		pq.Analyzer = analyzer
+		return pq
+
+	case SearchFuzzy:
+		d := bleve.NewDisjunctionQuery()
+		for _, w := range strings.Fields(term) {
+			mq := bleve.NewMatchQuery(w)
+			mq.SetField(field)
+			mq.SetBoost(boost)
+			mq.Analyzer = analyzer
+			mq.SetFuzziness(1)
+			if utf8.RuneCountInString(w) > 6 {
+				mq.SetFuzziness(2)
+			}
+			d.AddQuery(mq)
+		}
+		return d
+
+	case SearchPrefix:
+		words := strings.Fields(term)
+		if len(words) == 0 {
+			return nil
+		}
+
+		// terms are indexed in lower case, the prefix is not analysed; a complete last word is matched as well,
+		// since the index holds stems (e.g. 'intellig' for 'intelligence')
+		last := words[len(words)-1]
+		lp := bleve.NewPrefixQuery(strings.ToLower(last))
+		lp.SetField(field)
+		lp.SetBoost(boost)
+		lm := bleve.NewMatchQuery(last)
+		lm.SetField(field)
+		lm.SetBoost(boost)
+		lm.Analyzer = analyzer
+		pq := bleve.NewDisjunctionQuery(lp, lm)
+		if len(words) == 1 {
+			return pq
+		}
+
+		mq := bleve.NewMatchQuery(strings.Join(words[:len(words)-1], " "))
+		mq.SetField(field)
+		mq.SetBoost(boost)
+		mq.Analyzer = analyzer
+		mq.SetOperator(query.MatchQueryOperatorAnd)
+		return bleve.NewConjunctionQuery(mq, pq)
+	}
+
+	// match, words with '*' or '?' are wildcards
+	d := bleve.NewDisjunctionQuery()
+	var words []string
+	for _, w := range strings.Fields(term) {
+		if strings.ContainsAny(w, "*?") {
+			wq := bleve.NewWildcardQuery(strings.ToLower(w))
+			wq.SetField(field)
+			wq.SetBoost(boost)
+			d.AddQuery(wq)
+			continue
+		}
+		words = append(words, w)
+	}
+
+	if len(words) > 0 {
+		mq := bleve.NewMatchQuery(strings.Join(words, " "))
+		mq.SetField(field)
+		mq.SetBoost(boost)
+		mq.Analyzer = analyzer
+		d.AddQuery(mq)
+	}
+
+	return d
+}
+
+// facetField return field of the search index for a facet ('language', 'template' or a taxonomy property, see
+// Site.SearchFacets), "" if there is none
+func facetField(site *Site, name string) string {
+	name = strings.TrimSpace(name)
+
+	switch name {
+	case "language", "template", "path":
+		return name
+	}
+
+	for _, f := range site.SearchFacets() {
+		if f == name {
+			return "taxonomy." + name
+		}
+	}
+
+	return ""
+}
+
+// search search index as requested, return at most Limit results starting at Offset (just the total and facets if
+// Limit is 0)
+func search(index bleve.Index, nodes []*Node, site *Site, r searchRequest) (*SearchResults, error) {
+	results := &SearchResults{Offset: r.Offset, Limit: r.Limit}
+
+	term := strings.TrimSpace(r.Term)
+	if term == "" || r.Limit < 0 || r.Offset < 0 {
+		return results, nil
+	}
+
+	mode := strings.ToLower(strings.TrimSpace(r.Mode))
+	switch mode {
+	case "":
+		mode = SearchMatch
+	case SearchMatch, SearchPhrase, SearchFuzzy, SearchPrefix, SearchQueryString:
+	default:
+		return nil, fmt.Errorf("unknown search mode '%s'", r.Mode)
+	}
+
+	languages := []string{strings.ToLower(strings.TrimSpace(r.Language))}
+	if languages[0] == "" {
+		languages = Languages(nodes)
+	}
+
+	q, err := searchQuery(term, mode, languages, site.SearchBoost())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.Filters) > 0 {
+		cq := bleve.NewConjunctionQuery(q)
+		for name, value := range r.Filters {
+			field := facetField(site, name)
+			if field == "" {
+				return nil, fmt.Errorf("unknown filter '%s'", name)
+			}
+
+			if field == "language" {
+				value = strings.ToLower(value)
+			}
+
+			tq := bleve.NewTermQuery(value)
+			tq.SetField(field)
+			cq.AddQuery(tq)
+		}
+		q = cq
+	}
+
+	req := bleve.NewSearchRequestOptions(q, r.Limit, r.Offset, false)
+	req.Highlight = bleve.NewHighlightWithStyle("html")
+	req.Highlight.AddField("content")
+	req.Fields = []string{"title", "description", "language"}
+
+	facetSize := r.FacetSize
+	if facetSize <= 0 {
+		facetSize = 10
+	}
+	for _, name := range r.Facets {
+		field := facetField(site, name)
+		if field == "" {
+			return nil, fmt.Errorf("unknown facet '%s'", name)
+		}
+		req.AddFacet(name, bleve.NewFacetRequest(field, facetSize))
+	}
+
+	start := time.Now()
+	resp, err := index.Search(req)
+	searchDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	results.Total = resp.Total
+	results.Took = resp.Took
+
+	for i, m := range resp.Hits {
+		var fragments []string
+		c := ""
+		for _, f := range m.Fragments {
+			c = fmt.Sprintf("%s<br/>%s", c, f[0])
+			fragments = append(fragments, f...)
+		}
+
+		results.Results = append(results.Results, SearchResult{
+			Index:       r.Offset + i + 1,
+			URL:         m.ID,
+			Score:       fmt.Sprintf("%.4f", m.Score),
+			Title:       hitField(m.Fields, "title"),
+			Description: hitField(m.Fields, "description"),
+			Language:    hitField(m.Fields, "language"),
+			Content:     c,
+			Fragments:   fragments,
+			score:       m.Score,
+		})
+	}
+
+	for _, name := range r.Facets {
+		facet := SearchFacet{Name: name}
+		if f, ok := resp.Facets[name]; ok {
+			facet.Missing = f.Missing
+			facet.Other = f.Other
+			for _, t := range f.Terms {
+				facet.Terms = append(facet.Terms, SearchFacetTerm{Term: t.Term, Count: t.Count})
+			}
+		}
+		results.Facets = append(results.Facets, facet)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,269 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// defaults of the JSON search endpoint unless set in site.xml
+	defaultSearchAPIPath           = "/_search"
+	defaultSearchAPIPageSize       = 10
+	defaultSearchAPIMaxPageSize    = 50
+	defaultSearchAPIMaxQueryLength = 200
+	defaultSearchAPIMaxAge         = "60"
+
+	// searchAPIMaxResults deepest result that can be paged to, so that deep paging does not get expensive
+	searchAPIMaxResults = 1000
+)
+
+// XMLSearchAPI xml representation of the JSON search endpoint (site: 'search-api')
+type XMLSearchAPI struct {
+	Path           string `xml:"path,attr"`
+	PageSize       string `xml:"page-size,attr"`
+	MaxPageSize    string `xml:"max-page-size,attr"`
+	MaxQueryLength string `xml:"max-query-length,attr"`
+	CachingPolicy
+}
+
+// SearchAPI JSON search endpoint of the site
+type SearchAPI struct {
+	xmlSearchAPI *XMLSearchAPI
+}
+
+// Path return path the endpoint is served on (from: 'path', defaults to /_search)
+func (s *SearchAPI) Path() string {
+	p := strings.TrimSpace(s.xmlSearchAPI.Path)
+	if p == "" {
+		return defaultSearchAPIPath
+	}
+
+	return "/" + strings.Trim(p, "/")
+}
+
+// PageSize return number of hits per page unless requested otherwise (from: 'page-size', defaults to 10)
+func (s *SearchAPI) PageSize() int {
+	n := positive("search-api: page-size", s.xmlSearchAPI.PageSize, defaultSearchAPIPageSize)
+	if m := s.MaxPageSize(); n > m {
+		return m
+	}
+
+	return n
+}
+
+// MaxPageSize return maximum number of hits per page (from: 'max-page-size', defaults to 50)
+func (s *SearchAPI) MaxPageSize() int {
+	return positive("search-api: max-page-size", s.xmlSearchAPI.MaxPageSize, defaultSearchAPIMaxPageSize)
+}
+
+// MaxQueryLength return maximum length of a query in characters (from: 'max-query-length', defaults to 200)
+func (s *SearchAPI) MaxQueryLength() int {
+	return positive("search-api: max-query-length", s.xmlSearchAPI.MaxQueryLength, defaultSearchAPIMaxQueryLength)
+}
+
+// CacheControl return value of the Cache-Control header of responses (from: 'max-age', defaults to 60 seconds)
+func (s *SearchAPI) CacheControl() string {
+	p := s.xmlSearchAPI.CachingPolicy
+	if strings.TrimSpace(p.MaxAge) == "" {
+		p.MaxAge = defaultSearchAPIMaxAge
+	}
+
+	return p.CacheControl()
+}
+
+// searchAPIResponse body of a response of the JSON search endpoint
+type searchAPIResponse struct {
+	Query    string         `json:"query"`
+	Language string         `json:"language"`
+	Total    uint64         `json:"total"`
+	Page     int            `json:"page"`
+	Size     int            `json:"size"`
+	Hits     []searchAPIHit `json:"hits"`
+}
+
+// searchAPIHit hit of the JSON search endpoint
+type searchAPIHit struct {
+	URL         string   `json:"url"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Language    string   `json:"language,omitempty"`
+	Score       float64  `json:"score"`
+	Fragments   []string `json:"fragments"`
+}
+
+// SearchAPI JSON search endpoint (see site.xml: 'search-api'): ?q=<query>&lang=<language>&page=<page>&size=<size>,
+// all languages if lang is not given
+func (core *Core) SearchAPI(w http.ResponseWriter, r *http.Request) {
+	info := requestInfo(r)
+	info.Resolution = ResolutionSearch
+
+	_, _, err := core.setSecurityHeaders(w)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		http.Error(w, "", 500)
+		return
+	}
+
+	api := core.Site.SearchAPI()
+	if api == nil {
+		// not enabled in site.xml
+		info.Resolution = ResolutionNotFound
+		http.NotFound(w, r)
+		return
+	}
+
+	if core.ftindex == nil {
+		searchAPIError(w, 503, "search is disabled")
+		return
+	}
+
+	q := r.URL.Query()
+
+	term := strings.TrimSpace(q.Get("q"))
+	if term == "" {
+		searchAPIError(w, 400, "missing query (q)")
+		return
+	}
+	if utf8.RuneCountInString(term) > api.MaxQueryLength() {
+		searchAPIError(w, 400, "query too long, at most "+strconv.Itoa(api.MaxQueryLength())+" characters")
+		return
+	}
+
+	for _, word := range strings.Fields(term) {
+		if strings.HasPrefix(word, "*") || strings.HasPrefix(word, "?") {
+			// would scan all terms of the index
+			searchAPIError(w, 400, "wildcards must not lead a word")
+			return
+		}
+	}
+
+	language := strings.ToLower(strings.TrimSpace(q.Get("lang")))
+	if !languageTag(language) {
+		searchAPIError(w, 400, "invalid language (lang)")
+		return
+	}
+
+	page := 1
+	if v := q.Get("page"); v != "" {
+		if page, err = strconv.Atoi(v); err != nil || page < 1 {
+			searchAPIError(w, 400, "invalid page")
+			return
+		}
+	}
+
+	size := api.PageSize()
+	if v := q.Get("size"); v != "" {
+		if size, err = strconv.Atoi(v); err != nil || size < 1 {
+			searchAPIError(w, 400, "invalid size")
+			return
+		}
+		if size > api.MaxPageSize() {
+			size = api.MaxPageSize()
+		}
+	}
+
+	resp := searchAPIResponse{Query: term, Language: language, Page: page, Size: size, Hits: []searchAPIHit{}}
+
+	offset, limit := (page-1)*size, size
+	if offset+limit > searchAPIMaxResults {
+		// beyond the deepest result that can be paged to, total only
+		offset, limit = 0, 0
+		if (page-1)*size < searchAPIMaxResults {
+			offset, limit = (page-1)*size, searchAPIMaxResults-(page-1)*size
+		}
+	}
+
+	results, err := search(core.ftindex, core.Nodes, core.Site, searchRequest{Term: term, Language: language, Offset: offset, Limit: limit})
+	if err != nil {
+		log.Error().Msg(err.Error())
+		searchAPIError(w, 500, "search failed")
+		return
+	}
+
+	resp.Total = results.Total
+	for _, result := range results.Results {
+		fragments := result.Fragments
+		if fragments == nil {
+			fragments = []string{}
+		}
+
+		resp.Hits = append(resp.Hits, searchAPIHit{
+			URL:         core.Site.URL(template.URL(result.URL)),
+			Title:       result.Title,
+			Description: result.Description,
+			Language:    result.Language,
+			Score:       result.score,
+			Fragments:   fragments,
+		})
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		searchAPIError(w, 500, "search failed")
+		return
+	}
+
+	writeJSON(w, r, body, api.CacheControl())
+}
+
+// writeJSON send body (JSON) with an ETag and cacheControl, or only the status if a precondition of r applies
+// (e.g. 304), of the search and suggestions endpoints
+func writeJSON(w http.ResponseWriter, r *http.Request, body []byte, cacheControl string) {
+	etag := newETag(body)
+	w.Header().Set("Etag", etag)
+	w.Header().Set("Cache-Control", cacheControl)
+
+	if status := checkPreconditions(r, etag, time.Time{}); status != 0 {
+		w.WriteHeader(status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+
+	if strings.ToUpper(r.Method) == "HEAD" {
+		return
+	}
+
+	if _, err := w.Write(body); err != nil {
+		w.WriteHeader(500)
+	}
+}
+
+// languageTag return if language may be a language tag, i.e. is not longer than any (see RFC 5646)
+func languageTag(language string) bool {
+	return len(language) <= 35
+}
+
+// searchAPIError send error of the JSON search endpoint, never cached
+func searchAPIError(w http.ResponseWriter, status int, message string) {
+	body, _ := json.Marshal(map[string]string{"error": message})
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// positive return v as positive integer, def if v is not set or invalid
+func positive(name string, v string, def int) int {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		log.Warn().Msg(fmt.Sprintf("site.xml: %s: invalid value '%s'", name, v))
+		return def
+	}
+
+	return n
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/xml"
+	"net/http"
+	"strings"
+)
+
+// CSPNonceVariable is replaced by the per-request nonce in the Content-Security-Policy header
+const CSPNonceVariable = "{nonce}"
+
+// SecurityHeader optional security header, enabled if present, falls back to a sensible default if empty
+type SecurityHeader struct {
+	Value string `xml:",chardata"`
+}
+
+// Security struct, security headers sent with every response (from: 'security-headers.xml')
+type Security struct {
+	XMLName                 xml.Name        `xml:"security-headers"`
+	ContentSecurityPolicy   *SecurityHeader `xml:"content-security-policy"`
+	StrictTransportSecurity *SecurityHeader `xml:"strict-transport-security"`
+	ReferrerPolicy          *SecurityHeader `xml:"referrer-policy"`
+	PermissionsPolicy       *SecurityHeader `xml:"permissions-policy"`
+	XContentTypeOptions     *SecurityHeader `xml:"x-content-type-options"`
+}
+
+// Read read security headers from []byte
+func (s *Security) Read(r []byte) error {
+	return xml.Unmarshal(r, &s)
+}
+
+// Headers return all enabled security headers, nonce is substituted for CSPNonceVariable
+func (s *Security) Headers(nonce string) http.Header {
+	h := make(http.Header)
+
+	set := func(key string, header *SecurityHeader, preset string) {
+		if header == nil {
+			return
+		}
+
+		v := strings.Join(strings.Fields(header.Value), " ")
+		if v == "" {
+			v = preset
+		}
+
+		if v != "" {
+			h.Set(key, v)
+		}
+	}
+
+	if s.ContentSecurityPolicy != nil {
+		csp := strings.Join(strings.Fields(s.ContentSecurityPolicy.Value), " ")
+		set("Content-Security-Policy", &SecurityHeader{Value: strings.Replace(csp, CSPNonceVariable, nonce, -1)}, "")
+	}
+	set("Strict-Transport-Security", s.StrictTransportSecurity, "max-age=63072000; includeSubDomains")
+	set("Referrer-Policy", s.ReferrerPolicy, "strict-origin-when-cross-origin")
+	set("Permissions-Policy", s.PermissionsPolicy, "camera=(), microphone=(), geolocation=(), interest-cohort=()")
+	set("X-Content-Type-Options", s.XContentTypeOptions, "nosniff")
+
+	return h
+}
+
+// newNonce return a cryptographically random nonce, e.g. for use in a Content-Security-Policy
+func newNonce() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// setSecurityHeaders set the security headers (see security-headers.xml) with a new CSP nonce on w, return the
+// nonce and the headers set
+func (core *Core) setSecurityHeaders(w http.ResponseWriter) (string, http.Header, error) {
+	nonce, err := newNonce()
+	if err != nil {
+		return "", nil, err
+	}
+
+	security := core.Security.Headers(nonce)
+	for k, v := range security {
+		w.Header()[k] = v
+	}
+
+	return nonce, security, nil
+}
@@ -0,0 +1,373 @@
+package core
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/units"
+)
+
+// XMLSite xml representation of the site configuration (site.xml)
+type XMLSite struct {
+	XMLName         xml.Name         `xml:"site"`
+	Title           string           `xml:"title"`
+	Description     string           `xml:"description"`
+	Version         string           `xml:"version"`
+	BaseURL         string           `xml:"base-url"`
+	DefaultLanguage string           `xml:"default-language"`
+	DefaultTemplate string           `xml:"default-template"`
+	Minify          string           `xml:"minify"`
+	Compression     string           `xml:"compression"`
+	Search          string           `xml:"search"`
+	SearchBoost     []XMLSearchBoost `xml:"search-boost"`
+	SearchProperty  []string         `xml:"search-property"`
+	SearchFacet     []string         `xml:"search-facet"`
+	SearchAPI       *XMLSearchAPI    `xml:"search-api"`
+	SuggestAPI      *XMLSuggestAPI   `xml:"suggest-api"`
+	Server          XMLServer        `xml:"server"`
+	Property        []XMLProperty    `xml:"property"`
+}
+
+// XMLSearchBoost xml representation of the boost of a field of the search index
+type XMLSearchBoost struct {
+	Field string `xml:"field,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// XMLServer xml representation of the server options in site.xml
+type XMLServer struct {
+	Listen          []string `xml:"listen"`
+	Port            string   `xml:"port"`
+	SocketMode      string   `xml:"socket-mode"`
+	SocketOwner     string   `xml:"socket-owner"`
+	TLSCert         string   `xml:"tls-cert"`
+	TLSKey          string   `xml:"tls-key"`
+	RedirectPort    string   `xml:"redirect-port"`
+	ReadTimeout     string   `xml:"read-timeout"`
+	WriteTimeout    string   `xml:"write-timeout"`
+	IdleTimeout     string   `xml:"idle-timeout"`
+	HandlerTimeout  string   `xml:"handler-timeout"`
+	ShutdownTimeout string   `xml:"shutdown-timeout"`
+	LargeFileSize   string   `xml:"large-file-size"`
+	AccessLog       string   `xml:"access-log"`
+	AnonymiseIP     string   `xml:"anonymise-ip"`
+	MetricsPort     string   `xml:"metrics-port"`
+}
+
+// Site site configuration (from: 'site.xml'), available to templates as .Site
+type Site struct {
+	xmlSite XMLSite
+}
+
+// Read read site configuration from []byte
+func (s *Site) Read(r []byte) error {
+	return xml.Unmarshal(r, &s.xmlSite)
+}
+
+// Title return site title (from: 'title')
+func (s *Site) Title() string {
+	return strings.TrimSpace(s.xmlSite.Title)
+}
+
+// Description return site description (from: 'description')
+func (s *Site) Description() string {
+	return strings.TrimSpace(s.xmlSite.Description)
+}
+
+// Version return site version, e.g. a release tag or commit id (from: 'version')
+func (s *Site) Version() string {
+	return strings.TrimSpace(s.xmlSite.Version)
+}
+
+// BaseURL return base URL of the site without trailing slash, e.g. 'https://www.example.com' (from: 'base-url')
+func (s *Site) BaseURL() string {
+	return strings.TrimSuffix(strings.TrimSpace(s.xmlSite.BaseURL), "/")
+}
+
+// URL return absolute URL for path, e.g. for canonical links or sitemaps
+func (s *Site) URL(path template.URL) string {
+	return s.BaseURL() + string(path)
+}
+
+// DefaultLanguage return language used if none of the languages accepted by the client is available
+// (from: 'default-language')
+func (s *Site) DefaultLanguage() string {
+	return strings.ToLower(strings.TrimSpace(s.xmlSite.DefaultLanguage))
+}
+
+// DefaultTemplate return template used for nodes without template (from: 'default-template')
+func (s *Site) DefaultTemplate() string {
+	return strings.ToLower(strings.TrimSpace(s.xmlSite.DefaultTemplate))
+}
+
+// Minify return if rendered nodes are minified (from: 'minify', defaults to true)
+func (s *Site) Minify() bool {
+	return toggle(s.xmlSite.Minify, true)
+}
+
+// Compression return if content is compressed (from: 'compression', defaults to true)
+func (s *Site) Compression() bool {
+	return toggle(s.xmlSite.Compression, true)
+}
+
+// Search return if the full-text index is built (from: 'search', defaults to true)
+func (s *Site) Search() bool {
+	return toggle(s.xmlSite.Search, true)
+}
+
+// SearchBoost return boosts of the fields searched by default (from: 'search-boost'), defaults to title 3,
+// description 2, path 1.5, content and custom properties (see SearchProperties) 1
+func (s *Site) SearchBoost() map[string]float64 {
+	boost := map[string]float64{"title": 3, "description": 2, "path": 1.5, "content": 1}
+
+	if s == nil {
+		return boost
+	}
+
+	for _, p := range s.SearchProperties() {
+		boost["properties."+p] = 1
+	}
+
+	for _, b := range s.xmlSite.SearchBoost {
+		f := strings.TrimSpace(b.Field)
+		if _, ok := boost[f]; !ok {
+			log.Warn().Msg(fmt.Sprintf("site.xml: search-boost: unknown field '%s'", f))
+			continue
+		}
+
+		v, err := strconv.ParseFloat(strings.TrimSpace(b.Value), 64)
+		if err != nil || v < 0 {
+			log.Warn().Msg(fmt.Sprintf("site.xml: search-boost: invalid value '%s'", b.Value))
+			continue
+		}
+
+		boost[f] = v
+	}
+
+	return boost
+}
+
+// SearchProperties return keys of the custom properties of nodes added to the search index (from: 'search-property')
+func (s *Site) SearchProperties() []string {
+	var p []string
+
+	if s == nil {
+		return p
+	}
+
+	for _, k := range s.xmlSite.SearchProperty {
+		if k = strings.TrimSpace(k); k != "" {
+			p = append(p, k)
+		}
+	}
+
+	return p
+}
+
+// SearchFacets return keys of the custom properties of nodes added to the search index as taxonomy (comma separated
+// values that are not analysed), e.g. for facets by category (from: 'search-facet')
+func (s *Site) SearchFacets() []string {
+	var f []string
+
+	if s == nil {
+		return f
+	}
+
+	for _, k := range s.xmlSite.SearchFacet {
+		if k = strings.TrimSpace(k); k != "" {
+			f = append(f, k)
+		}
+	}
+
+	return f
+}
+
+// SearchAPI return JSON search endpoint, nil if not enabled (from: 'search-api')
+func (s *Site) SearchAPI() *SearchAPI {
+	if s == nil || s.xmlSite.SearchAPI == nil || !s.Search() {
+		return nil
+	}
+
+	return &SearchAPI{xmlSearchAPI: s.xmlSite.SearchAPI}
+}
+
+// SuggestAPI return suggestions endpoint, nil if not enabled (from: 'suggest-api')
+func (s *Site) SuggestAPI() *SuggestAPI {
+	if s == nil || s.xmlSite.SuggestAPI == nil || !s.Search() {
+		return nil
+	}
+
+	return &SuggestAPI{xmlSuggestAPI: s.xmlSite.SuggestAPI}
+}
+
+// CustomProperty return custom property (from: 'property')
+func (s *Site) CustomProperty(key string) string {
+	for _, p := range s.xmlSite.Property {
+		if p.Key == key {
+			return p.Value
+		}
+	}
+
+	return ""
+}
+
+// Listen return addresses to listen on (from: 'server/listen')
+func (s *Site) Listen() []string {
+	var l []string
+
+	for _, a := range s.xmlSite.Server.Listen {
+		if a = strings.TrimSpace(a); a != "" {
+			l = append(l, a)
+		}
+	}
+
+	return l
+}
+
+// Port return TCP port, 0 if not set (from: 'server/port')
+func (s *Site) Port() uint16 {
+	return s.port("port", s.xmlSite.Server.Port)
+}
+
+// SocketMode return permissions of UNIX domain sockets (octal), "" if not set (from: 'server/socket-mode')
+func (s *Site) SocketMode() string {
+	return strings.TrimSpace(s.xmlSite.Server.SocketMode)
+}
+
+// SocketOwner return owner of UNIX domain sockets (user[:group]), "" if not set (from: 'server/socket-owner')
+func (s *Site) SocketOwner() string {
+	return strings.TrimSpace(s.xmlSite.Server.SocketOwner)
+}
+
+// TLSCert return TLS certificate file, "" if not set (from: 'server/tls-cert')
+func (s *Site) TLSCert() string {
+	return strings.TrimSpace(s.xmlSite.Server.TLSCert)
+}
+
+// TLSKey return TLS private key file, "" if not set (from: 'server/tls-key')
+func (s *Site) TLSKey() string {
+	return strings.TrimSpace(s.xmlSite.Server.TLSKey)
+}
+
+// RedirectPort return TCP port to redirect HTTP to HTTPS on, 0 if not set (from: 'server/redirect-port')
+func (s *Site) RedirectPort() uint16 {
+	return s.port("redirect-port", s.xmlSite.Server.RedirectPort)
+}
+
+// ReadTimeout return read timeout, 0 if not set (from: 'server/read-timeout')
+func (s *Site) ReadTimeout() time.Duration {
+	return s.duration("read-timeout", s.xmlSite.Server.ReadTimeout)
+}
+
+// WriteTimeout return write timeout, 0 if not set (from: 'server/write-timeout')
+func (s *Site) WriteTimeout() time.Duration {
+	return s.duration("write-timeout", s.xmlSite.Server.WriteTimeout)
+}
+
+// IdleTimeout return idle timeout, 0 if not set (from: 'server/idle-timeout')
+func (s *Site) IdleTimeout() time.Duration {
+	return s.duration("idle-timeout", s.xmlSite.Server.IdleTimeout)
+}
+
+// HandlerTimeout return handler timeout, 0 if not set (from: 'server/handler-timeout')
+func (s *Site) HandlerTimeout() time.Duration {
+	return s.duration("handler-timeout", s.xmlSite.Server.HandlerTimeout)
+}
+
+// TimeoutSet return if the timeout name (e.g. 'handler-timeout') is set to a valid duration in site.xml, which may
+// be 0 (= no timeout)
+func (s *Site) TimeoutSet(name string) bool {
+	v := map[string]string{
+		"read-timeout":     s.xmlSite.Server.ReadTimeout,
+		"write-timeout":    s.xmlSite.Server.WriteTimeout,
+		"idle-timeout":     s.xmlSite.Server.IdleTimeout,
+		"handler-timeout":  s.xmlSite.Server.HandlerTimeout,
+		"shutdown-timeout": s.xmlSite.Server.ShutdownTimeout,
+	}[name]
+
+	_, err := time.ParseDuration(strings.TrimSpace(v))
+	return err == nil
+}
+
+// ShutdownTimeout return shutdown timeout, 0 if not set (from: 'server/shutdown-timeout')
+func (s *Site) ShutdownTimeout() time.Duration {
+	return s.duration("shutdown-timeout", s.xmlSite.Server.ShutdownTimeout)
+}
+
+// LargeFileSize return size above which public files are exempt from timeouts, 0 if not set
+// (from: 'server/large-file-size')
+func (s *Site) LargeFileSize() units.Base2Bytes {
+	v := strings.TrimSpace(s.xmlSite.Server.LargeFileSize)
+	if v == "" {
+		return 0
+	}
+
+	b, err := units.ParseBase2Bytes(v)
+	if err != nil {
+		log.Warn().Msg(fmt.Sprintf("site.xml: large-file-size: %s", err.Error()))
+		return 0
+	}
+
+	return b
+}
+
+// AccessLog return access log format ('json' or 'combined'), "" if not set (from: 'server/access-log')
+func (s *Site) AccessLog() string {
+	return strings.ToLower(strings.TrimSpace(s.xmlSite.Server.AccessLog))
+}
+
+// AnonymiseIP return if client IP addresses are anonymised in the access log (from: 'server/anonymise-ip',
+// defaults to false)
+func (s *Site) AnonymiseIP() bool {
+	return toggle(s.xmlSite.Server.AnonymiseIP, false)
+}
+
+// MetricsPort return TCP port to expose Prometheus metrics on, 0 if not set (from: 'server/metrics-port')
+func (s *Site) MetricsPort() uint16 {
+	return s.port("metrics-port", s.xmlSite.Server.MetricsPort)
+}
+
+func (s *Site) port(name string, v string) uint16 {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+
+	p, err := strconv.ParseUint(v, 10, 16)
+	if err != nil {
+		log.Warn().Msg(fmt.Sprintf("site.xml: %s: %s", name, err.Error()))
+		return 0
+	}
+
+	return uint16(p)
+}
+
+func (s *Site) duration(name string, v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Warn().Msg(fmt.Sprintf("site.xml: %s: %s", name, err.Error()))
+		return 0
+	}
+
+	return d
+}
+
+// toggle return if a feature toggle is on, def if it is not set
+func toggle(v string, def bool) bool {
+	v = strings.ToLower(strings.TrimSpace(v))
+
+	if v == "" {
+		return def
+	}
+
+	return v == "1" || v == "on" || strings.HasPrefix(v, "enable") || v == "true"
+}
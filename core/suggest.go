@@ -0,0 +1,307 @@
+package core
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// defaults of the suggestions endpoint unless set in site.xml
+	defaultSuggestAPIPath    = "/_suggest"
+	defaultSuggestAPISize    = 8
+	defaultSuggestAPIMaxSize = 20
+	defaultSuggestAPIMaxAge  = "300"
+
+	// suggestMaxQueryLength maximum length of a prefix in characters
+	suggestMaxQueryLength = 100
+)
+
+// XMLSuggestAPI xml representation of the suggestions endpoint (site: 'suggest-api')
+type XMLSuggestAPI struct {
+	Path    string `xml:"path,attr"`
+	Size    string `xml:"size,attr"`
+	MaxSize string `xml:"max-size,attr"`
+	CachingPolicy
+}
+
+// SuggestAPI suggestions endpoint of the site
+type SuggestAPI struct {
+	xmlSuggestAPI *XMLSuggestAPI
+}
+
+// Path return path the endpoint is served on (from: 'path', defaults to /_suggest)
+func (s *SuggestAPI) Path() string {
+	p := strings.TrimSpace(s.xmlSuggestAPI.Path)
+	if p == "" {
+		return defaultSuggestAPIPath
+	}
+
+	return "/" + strings.Trim(p, "/")
+}
+
+// Size return number of suggestions unless requested otherwise (from: 'size', defaults to 8)
+func (s *SuggestAPI) Size() int {
+	n := positive("suggest-api: size", s.xmlSuggestAPI.Size, defaultSuggestAPISize)
+	if m := s.MaxSize(); n > m {
+		return m
+	}
+
+	return n
+}
+
+// MaxSize return maximum number of suggestions (from: 'max-size', defaults to 20)
+func (s *SuggestAPI) MaxSize() int {
+	return positive("suggest-api: max-size", s.xmlSuggestAPI.MaxSize, defaultSuggestAPIMaxSize)
+}
+
+// CacheControl return value of the Cache-Control header of responses (from: 'max-age', defaults to 300 seconds)
+func (s *SuggestAPI) CacheControl() string {
+	p := s.xmlSuggestAPI.CachingPolicy
+	if strings.TrimSpace(p.MaxAge) == "" {
+		p.MaxAge = defaultSuggestAPIMaxAge
+	}
+
+	return p.CacheControl()
+}
+
+// Suggestion title and path of a node suggested for a prefix
+type Suggestion struct {
+	Title    string
+	URL      string
+	Language string
+}
+
+// suggestKey word of the title or a key term of a node, in lower case
+type suggestKey struct {
+	word  string
+	node  *Node
+	title bool
+}
+
+// suggestIndex prefix index over the titles and key terms of nodes, sorted by word
+type suggestIndex struct {
+	keys []suggestKey
+	text map[*Node]string
+}
+
+// newSuggestIndex return prefix index over the titles and key terms (custom properties in Site.SearchProperties and
+// Site.SearchFacets) of all nodes that are not disabled; publishing state is checked on lookup, so that the index
+// does not have to be rebuilt when nodes get published or expire
+func newSuggestIndex(nodes []*Node, site *Site) *suggestIndex {
+	si := &suggestIndex{text: make(map[*Node]string)}
+
+	for _, node := range nodes {
+		if !node.enabled() {
+			continue
+		}
+
+		seen := make(map[string]bool)
+		add := func(s string, title bool) {
+			for _, w := range suggestWords(s) {
+				if !seen[w] {
+					seen[w] = true
+					si.keys = append(si.keys, suggestKey{word: w, node: node, title: title})
+				}
+			}
+		}
+
+		add(node.Title(), true)
+		text := []string{node.Title()}
+		for _, p := range append(site.SearchProperties(), site.SearchFacets()...) {
+			if v := node.CustomProperty(p, false); v != "" {
+				add(v, false)
+				text = append(text, v)
+			}
+		}
+
+		si.text[node] = " " + strings.Join(suggestWords(strings.Join(text, " ")), " ") + " "
+	}
+
+	sort.SliceStable(si.keys, func(i, j int) bool {
+		return si.keys[i].word < si.keys[j].word
+	})
+
+	return si
+}
+
+// suggestWords return words of s in lower case, split at anything that is neither a letter nor a digit
+func suggestWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Lookup return at most n enabled nodes in language ("" for all languages) whose title or key terms have a word
+// starting with the last word of prefix and contain all other words of it. Titles starting with prefix rank first,
+// then matches in the title, then matches in key terms; nodes with a lower weight rank higher within each group.
+func (si *suggestIndex) Lookup(prefix string, language string, n int) []Suggestion {
+	words := suggestWords(prefix)
+	if si == nil || len(words) == 0 || n <= 0 {
+		return nil
+	}
+
+	last := words[len(words)-1]
+	whole := strings.Join(words, " ")
+	language = strings.ToLower(strings.TrimSpace(language))
+	now := time.Now()
+
+	rank := make(map[*Node]int)
+	for i := sort.Search(len(si.keys), func(i int) bool { return si.keys[i].word >= last }); i < len(si.keys); i++ {
+		k := si.keys[i]
+		if !strings.HasPrefix(k.word, last) {
+			break
+		}
+
+		if language != "" && strings.ToLower(k.node.Language()) != language {
+			continue
+		}
+
+		if !k.node.enabled() || !k.node.Published(now) {
+			continue
+		}
+
+		matches := true
+		for _, w := range words[:len(words)-1] {
+			if !strings.Contains(si.text[k.node], " "+w+" ") {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+
+		r := 1
+		if strings.HasPrefix(strings.Join(suggestWords(k.node.Title()), " "), whole) {
+			r = 3
+		} else if k.title {
+			r = 2
+		}
+		if r > rank[k.node] {
+			rank[k.node] = r
+		}
+	}
+
+	nodes := make([]*Node, 0, len(rank))
+	for node := range rank {
+		nodes = append(nodes, node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		if rank[nodes[i]] != rank[nodes[j]] {
+			return rank[nodes[i]] > rank[nodes[j]]
+		}
+		if nodes[i].Weight() != nodes[j].Weight() {
+			return nodes[i].Weight() < nodes[j].Weight()
+		}
+		if len(nodes[i].Title()) != len(nodes[j].Title()) {
+			return len(nodes[i].Title()) < len(nodes[j].Title())
+		}
+		return nodes[i].Path() < nodes[j].Path()
+	})
+
+	if len(nodes) > n {
+		nodes = nodes[:n]
+	}
+
+	var suggestions []Suggestion
+	for _, node := range nodes {
+		suggestions = append(suggestions, Suggestion{Title: node.Title(), URL: string(node.Path()), Language: node.Language()})
+	}
+
+	return suggestions
+}
+
+// suggestAPIResponse body of a response of the suggestions endpoint
+type suggestAPIResponse struct {
+	Query       string          `json:"query"`
+	Language    string          `json:"language"`
+	Suggestions []suggestAPIHit `json:"suggestions"`
+}
+
+// suggestAPIHit suggestion of the suggestions endpoint
+type suggestAPIHit struct {
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+	Language string `json:"language,omitempty"`
+}
+
+// SuggestAPI suggestions endpoint (see site.xml: 'suggest-api'): ?q=<prefix>&lang=<language>&size=<size>, the
+// language accepted by the visitor if lang is not given (all languages if there are no nodes in it)
+func (core *Core) SuggestAPI(w http.ResponseWriter, r *http.Request) {
+	info := requestInfo(r)
+	info.Resolution = ResolutionSearch
+
+	_, _, err := core.setSecurityHeaders(w)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		http.Error(w, "", 500)
+		return
+	}
+
+	api := core.Site.SuggestAPI()
+	if api == nil {
+		// not enabled in site.xml
+		info.Resolution = ResolutionNotFound
+		http.NotFound(w, r)
+		return
+	}
+
+	q := r.URL.Query()
+
+	prefix := strings.TrimSpace(q.Get("q"))
+	if prefix == "" {
+		searchAPIError(w, 400, "missing prefix (q)")
+		return
+	}
+	if utf8.RuneCountInString(prefix) > suggestMaxQueryLength {
+		searchAPIError(w, 400, "prefix too long, at most "+strconv.Itoa(suggestMaxQueryLength)+" characters")
+		return
+	}
+
+	language := strings.ToLower(strings.TrimSpace(q.Get("lang")))
+	if _, ok := q["lang"]; !ok {
+		language = acceptedLanguage(r, core.Nodes)
+		w.Header().Add("Vary", "Accept-Language")
+	}
+	if !languageTag(language) {
+		searchAPIError(w, 400, "invalid language (lang)")
+		return
+	}
+
+	size := api.Size()
+	if v := q.Get("size"); v != "" {
+		if size, err = strconv.Atoi(v); err != nil || size < 1 {
+			searchAPIError(w, 400, "invalid size")
+			return
+		}
+		if size > api.MaxSize() {
+			size = api.MaxSize()
+		}
+	}
+
+	resp := suggestAPIResponse{Query: prefix, Language: language, Suggestions: []suggestAPIHit{}}
+	for _, s := range core.suggestions.Lookup(prefix, language, size) {
+		resp.Suggestions = append(resp.Suggestions, suggestAPIHit{
+			Title:    s.Title,
+			URL:      core.Site.URL(template.URL(s.URL)),
+			Language: s.Language,
+		})
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		searchAPIError(w, 500, "suggestions failed")
+		return
+	}
+
+	writeJSON(w, r, body, api.CacheControl())
+}
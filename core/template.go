@@ -4,12 +4,14 @@ import (
 	"encoding/xml"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Template struct
 type Template struct {
-	xmlTemplate XMLTemplate
-	name        string
+	xmlTemplate        XMLTemplate
+	name               string
+	contentFileModTime time.Time
 }
 
 // XMLTemplate struct
@@ -24,6 +26,8 @@ type XMLTemplate struct {
 	Engine       string   `xml:"engine"`
 	Content      string   `xml:"content"`
 	ContentFile  string   `xml:"content-file"`
+	Cacheable    string   `xml:"cacheable"`
+	CacheControl string   `xml:"cache-control"`
 }
 
 func (t *Template) Read(r []byte) error {
@@ -83,3 +87,22 @@ func (t *Template) SetContent(content string) {
 func (t *Template) ContentFile() string {
 	return t.xmlTemplate.ContentFile
 }
+
+// ContentFileModTime return modification time of ContentFile (zero time if there is none)
+func (t *Template) ContentFileModTime() time.Time {
+	return t.contentFileModTime
+}
+
+// CacheControl return value of the Cache-Control header for nodes using this template (field: 'cache-control')
+func (t *Template) CacheControl() string {
+	return strings.TrimSpace(t.xmlTemplate.CacheControl)
+}
+
+// Cacheable return if the output of the template depends on nothing but the node, so that it can be rendered
+// once and served from memory (field: 'cacheable'). Templates have to opt in, templates making use of the HTTP
+// request, the draft preview mode or the full-text search must not.
+func (t *Template) Cacheable() bool {
+	c := strings.ToLower(strings.TrimSpace(t.xmlTemplate.Cacheable))
+
+	return c == "1" || c == "on" || strings.HasPrefix(c, "enable") || c == "true"
+}
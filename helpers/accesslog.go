@@ -0,0 +1,129 @@
+package helpers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/rs/zerolog"
+
+	"github.com/THREATINT/onacms/core"
+)
+
+// Access log formats
+const (
+	AccessLogJSON     = "json"
+	AccessLogCombined = "combined"
+)
+
+// AccessLog log every request, either as JSON or in combined log format (NCSA),
+// with the client IP address anonymised if anonymise is set
+func AccessLog(log *zerolog.Logger, format string, anonymise bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			r, info := core.WithRequestInfo(r)
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					// nothing written, net/http sends 200
+					status = 200
+				}
+
+				ip := remoteIP(r)
+				if anonymise {
+					ip = AnonymiseIP(ip)
+				}
+
+				duration := time.Since(start)
+
+				if format == AccessLogCombined {
+					log.Log().Msg(combined(r, ip, start, status, ww.BytesWritten(), duration, info))
+					return
+				}
+
+				log.Log().
+					Time("time", start).
+					Str("remote_ip", ip).
+					Str("method", r.Method).
+					Str("host", r.Host).
+					Str("uri", r.RequestURI).
+					Str("proto", r.Proto).
+					Int("status", status).
+					Int("bytes", ww.BytesWritten()).
+					Float64("duration_ms", float64(duration.Microseconds())/1000).
+					Str("referer", r.Referer()).
+					Str("user_agent", r.UserAgent()).
+					Str("resolution", info.Resolution).
+					Str("target", info.Target).
+					Str("cache", info.Cache).
+					Send()
+			}()
+
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+// combined return log line in combined log format, followed by duration (ms), resolution, target and page cache result
+func combined(r *http.Request, ip string, t time.Time, status int, bytes int, duration time.Duration, info *core.RequestInfo) string {
+	b := "-"
+	if bytes > 0 {
+		b = strconv.Itoa(bytes)
+	}
+
+	return fmt.Sprintf("%s - - [%s] %s %d %s %s %s %.3f %s %s %s",
+		ip,
+		t.Format("02/Jan/2006:15:04:05 -0700"),
+		strconv.Quote(fmt.Sprintf("%s %s %s", r.Method, r.RequestURI, r.Proto)),
+		status,
+		b,
+		strconv.Quote(dash(r.Referer())),
+		strconv.Quote(dash(r.UserAgent())),
+		float64(duration.Microseconds())/1000,
+		dash(info.Resolution),
+		dash(info.Target),
+		dash(info.Cache),
+	)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+// remoteIP return IP address of the client (without port)
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// e.g. UNIX domain socket
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// AnonymiseIP return ip with the host part removed: the last octet of IPv4 addresses,
+// the last 80 bits of IPv6 addresses
+func AnonymiseIP(ip string) string {
+	p := net.ParseIP(strings.TrimSpace(ip))
+	if p == nil {
+		return ip
+	}
+
+	if p4 := p.To4(); p4 != nil {
+		return p4.Mask(net.CIDRMask(24, 32)).String()
+	}
+
+	return p.Mask(net.CIDRMask(48, 128)).String()
+}
@@ -0,0 +1,101 @@
+package helpers
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// CertificateReloader keep TLS certificate and key in memory, reload them on SIGHUP or when the files change
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+	log      *zerolog.Logger
+
+	mutex   sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewCertificateReloader initialiser, loads certificate and key for the first time
+func NewCertificateReloader(certFile string, keyFile string, log *zerolog.Logger) (*CertificateReloader, error) {
+	c := &CertificateReloader{certFile: certFile, keyFile: keyFile, log: log}
+
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Reload (re)load certificate and key from disk, keep the current certificate on error
+func (c *CertificateReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.cert = &cert
+	c.modTime = c.latestModTime()
+
+	return nil
+}
+
+// GetCertificate return current certificate (see tls.Config)
+func (c *CertificateReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.cert, nil
+}
+
+// Watch reload certificate and key on SIGHUP or when one of the files has been modified (checked every interval),
+// does not return
+func (c *CertificateReloader) Watch(interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-hup:
+		case <-ticker.C:
+			c.mutex.RLock()
+			modified := c.latestModTime().After(c.modTime)
+			c.mutex.RUnlock()
+
+			if !modified {
+				continue
+			}
+		}
+
+		if err := c.Reload(); err != nil {
+			c.log.Error().Msg(fmt.Sprintf("reloading TLS certificate: %s", err.Error()))
+		} else {
+			c.log.Info().Msg("TLS certificate reloaded")
+		}
+	}
+}
+
+func (c *CertificateReloader) latestModTime() time.Time {
+	var t time.Time
+
+	for _, f := range []string{c.certFile, c.keyFile} {
+		if fi, err := os.Stat(f); err == nil && fi.ModTime().After(t) {
+			t = fi.ModTime()
+		}
+	}
+
+	return t
+}
@@ -0,0 +1,62 @@
+package helpers
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/THREATINT/onacms/core"
+)
+
+// Liveness answer 200 as long as the process is able to handle requests
+func Liveness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// Readiness answer 200 with the status of the Core returned by c (JSON), 503 while there is none or it is not ready
+func Readiness(c func() *core.Core) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := core.Status{}
+		if cc := c(); cc != nil {
+			status = cc.Status()
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/json")
+
+		if !status.Ready {
+			w.WriteHeader(503)
+		}
+
+		json.NewEncoder(w).Encode(status)
+	})
+}
+
+// Healthcheck probe url, return nil if it answers 200 (e.g. for 'onacms healthcheck' in Docker HEALTHCHECK)
+func Healthcheck(url string, timeout time.Duration) error {
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// the probe runs next to the server, its certificate is not issued for the loopback address
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	return nil
+}
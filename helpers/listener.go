@@ -0,0 +1,158 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// listenFdsStart first file descriptor passed by systemd (see sd_listen_fds(3))
+const listenFdsStart = 3
+
+// Listen return a listener for every address given, either 'tcp://host:port' or 'unix:///path/to/socket'.
+// UNIX domain sockets are created with permissions mode, owner is optional and given as 'user[:group]'.
+func Listen(addresses []string, mode os.FileMode, owner string) ([]net.Listener, error) {
+	var listeners []net.Listener
+
+	for _, a := range addresses {
+		l, err := listen(a, mode, owner)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("%s: %s", a, err.Error())
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
+
+func listen(address string, mode os.FileMode, owner string) (net.Listener, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "tcp", "tcp4", "tcp6":
+		return net.Listen(u.Scheme, u.Host)
+	case "unix":
+		p := u.Path
+		if p == "" {
+			// e.g. 'unix://onacms.sock' (relative path)
+			p = u.Host
+		}
+
+		// remove stale socket left behind e.g. by a crash
+		if fi, err := os.Lstat(p); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(p)
+		}
+
+		l, err := net.Listen("unix", p)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := os.Chmod(p, mode); err != nil {
+			l.Close()
+			return nil, err
+		}
+
+		if owner != "" {
+			uid, gid, err := lookupOwner(owner)
+			if err != nil {
+				l.Close()
+				return nil, err
+			}
+
+			if err := os.Chown(p, uid, gid); err != nil {
+				l.Close()
+				return nil, err
+			}
+		}
+
+		return l, nil
+	default:
+		return nil, errors.New("unsupported scheme, use tcp:// or unix://")
+	}
+}
+
+// lookupOwner return uid and gid for 'user[:group]', gid is -1 (= unchanged) if there is no group
+func lookupOwner(owner string) (int, int, error) {
+	o := strings.SplitN(owner, ":", 2)
+
+	uid, gid := -1, -1
+
+	if o[0] != "" {
+		u, err := user.Lookup(o[0])
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if len(o) == 2 && o[1] != "" {
+		g, err := user.LookupGroup(o[1])
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return uid, gid, nil
+}
+
+// SystemdListeners return the sockets passed by systemd (socket activation, see sd_listen_fds(3)),
+// nil if LISTEN_FDS is not set or meant for another process
+func SystemdListeners() ([]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	// the sockets must not be passed on to child processes
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	var listeners []net.Listener
+
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("LISTEN_FD_%d", listenFdsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(listenFdsStart+i), name)
+
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err.Error())
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
@@ -0,0 +1,64 @@
+package helpers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/THREATINT/onacms/core"
+)
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "onacms_http_requests_total",
+		Help: "Number of HTTP requests by status and resolution.",
+	}, []string{"status", "resolution"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "onacms_http_request_duration_seconds",
+		Help:    "Latency of HTTP requests by status and resolution.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"status", "resolution"})
+)
+
+func init() {
+	prometheus.MustRegister(requests, requestDuration)
+}
+
+// Metrics count requests and measure their latency by status and resolution (public file, node, endpoint, ...)
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		r, info := core.WithRequestInfo(r)
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		defer func() {
+			status := ww.Status()
+			if status == 0 {
+				// nothing written, net/http sends 200
+				status = 200
+			}
+
+			resolution := info.Resolution
+			if resolution == "" {
+				// e.g. method not allowed or bad request
+				resolution = "none"
+			}
+
+			requests.WithLabelValues(strconv.Itoa(status), resolution).Inc()
+			requestDuration.WithLabelValues(strconv.Itoa(status), resolution).Observe(time.Since(start).Seconds())
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// MetricsHandler return handler exposing all metrics in the Prometheus text format
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
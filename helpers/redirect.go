@@ -0,0 +1,24 @@
+package helpers
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// RedirectToHTTPS handler redirecting all requests to HTTPS on port
+func RedirectToHTTPS(port uint16) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			// no port in Host header
+			host = r.Host
+		}
+
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(int(port)))
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), 301)
+	})
+}
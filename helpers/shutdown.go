@@ -0,0 +1,45 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ShutdownOnSignal gracefully shut down servers on SIGINT or SIGTERM, waiting at most timeout for active
+// connections to finish. The channel returned is closed once all servers have been shut down.
+func ShutdownOnSignal(timeout time.Duration, log *zerolog.Logger, servers ...*http.Server) <-chan struct{} {
+	done := make(chan struct{})
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		s := <-sig
+		log.Info().Msg(fmt.Sprintf("%s received, shutting down (waiting %s at most)...", s, timeout))
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		for _, server := range servers {
+			if server == nil {
+				continue
+			}
+
+			if err := server.Shutdown(ctx); err != nil {
+				log.Error().Msg(fmt.Sprintf("shutdown: %s", err.Error()))
+				server.Close()
+			}
+		}
+
+		close(done)
+	}()
+
+	return done
+}
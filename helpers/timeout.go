@@ -0,0 +1,43 @@
+package helpers
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/THREATINT/onacms/core"
+)
+
+// Timeout limit the time a handler may take to timeout (see http.TimeoutHandler). Requests for which exempt
+// returns true, e.g. downloads of large files, are neither limited nor subject to the server's write timeout.
+// Middleware wrapping Timeout, e.g. access log and metrics, see the 503 sent on timeout. A zero timeout means no
+// limit, like the timeouts of http.Server.
+func Timeout(next http.Handler, timeout time.Duration, exempt func(*http.Request) bool) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exempt != nil && exempt(r) {
+			// a zero time means no deadline
+			http.NewResponseController(w).SetWriteDeadline(time.Time{})
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// after a timeout the handler keeps running, so it fills in a RequestInfo of its own, which is handed on
+		// only if it has finished in time
+		r, info := core.WithRequestInfo(r)
+		hr, hinfo := core.WithNewRequestInfo(r)
+
+		var done atomic.Bool
+		http.TimeoutHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			done.Store(true)
+		}), timeout, "").ServeHTTP(w, hr)
+
+		if done.Load() {
+			*info = *hinfo
+		}
+	})
+}
@@ -1,18 +1,20 @@
 package main
 
 import (
-	"bytes"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/user"
-	"path/filepath"
+	"strconv"
 	"strings"
-	"text/template"
+	"sync/atomic"
 	"time"
 
+	"github.com/alecthomas/units"
 	"github.com/go-chi/chi"
-	"github.com/go-chi/chi/middleware"
 	"github.com/rs/zerolog"
 	"github.com/spf13/afero"
 
@@ -24,15 +26,81 @@ import (
 
 func main() {
 	var (
-		dir  = kingpin.Flag("dir", "directory containing the site (/public /nodes /templates)").Default("/www").String()
-		port = kingpin.Flag("port", "(optional) TCP port").Default("10000").Int16()
+		dir  = kingpin.Flag("dir", "directory containing the site (/public /nodes /templates)").Default("/www").Envar("ONACMS_DIR").String()
+		port = kingpin.Flag("port", "(optional) TCP port, ignored if --listen is given (default: 10000)").Envar("ONACMS_PORT").Uint16()
+
+		listen      = kingpin.Flag("listen", "(optional, repeatable) address to listen on: tcp://[host]:port or unix:///path/to/socket").Envar("ONACMS_LISTEN").Strings()
+		socketMode  = kingpin.Flag("socket-mode", "(optional) permissions of UNIX domain sockets, octal (default: 0660)").Envar("ONACMS_SOCKET_MODE").String()
+		socketOwner = kingpin.Flag("socket-owner", "(optional) owner of UNIX domain sockets: user[:group]").Envar("ONACMS_SOCKET_OWNER").String()
+
+		tlsCert      = kingpin.Flag("tls-cert", "(optional) TLS certificate (PEM), enables HTTPS and HTTP/2, reloaded on SIGHUP or change").Envar("ONACMS_TLS_CERT").String()
+		tlsKey       = kingpin.Flag("tls-key", "(optional) TLS private key (PEM)").Envar("ONACMS_TLS_KEY").String()
+		redirectPort = kingpin.Flag("redirect-port", "(optional) TCP port to redirect plain HTTP to HTTPS on").Envar("ONACMS_REDIRECT_PORT").Uint16()
+
+		readTimeout     = kingpin.Flag("read-timeout", "(optional) maximum duration for reading a request (default: 2s)").Envar("ONACMS_READ_TIMEOUT").Duration()
+		writeTimeout    = kingpin.Flag("write-timeout", "(optional) maximum duration for writing a response (default: 4s)").Envar("ONACMS_WRITE_TIMEOUT").Duration()
+		idleTimeout     = kingpin.Flag("idle-timeout", "(optional) maximum duration to keep an idle connection open (default: 30s)").Envar("ONACMS_IDLE_TIMEOUT").Duration()
+		handlerTimeout  = kingpin.Flag("handler-timeout", "(optional) maximum duration for handling a request (default: 4s)").Envar("ONACMS_HANDLER_TIMEOUT").Duration()
+		shutdownTimeout = kingpin.Flag("shutdown-timeout", "(optional) maximum duration to wait for active connections on SIGTERM/SIGINT (default: 30s)").Envar("ONACMS_SHUTDOWN_TIMEOUT").Duration()
+		largeFileSize   = kingpin.Flag("large-file-size", "(optional) public files larger than this are exempt from write and handler timeout (default: 1MB)").Envar("ONACMS_LARGE_FILE_SIZE").Bytes()
+
+		accessLog   = kingpin.Flag("access-log", "(optional) log every request to stdout: json or combined").Envar("ONACMS_ACCESS_LOG").Enum("", "json", "combined")
+		anonymiseIP = kingpin.Flag("anonymise-ip", "(optional) remove the host part of client IP addresses in the access log").Envar("ONACMS_ANONYMISE_IP").Bool()
+
+		metricsPort = kingpin.Flag("metrics-port", "(optional) TCP port to expose Prometheus metrics on (/metrics)").Envar("ONACMS_METRICS_PORT").Uint16()
+
+		previewSecret = kingpin.Flag("preview-secret", "(optional) secret used to sign draft preview tokens, enables draft preview mode").Envar("ONACMS_PREVIEW_SECRET").String()
+		previewToken  = kingpin.Flag("preview-token", "(optional) print a draft preview token valid for the given duration (e.g. 24h) and exit").Duration()
+
+		formSecret   = kingpin.Flag("form-secret", "(optional) secret used to sign the CSRF tokens of forms, so that they stay valid across restarts and instances (default: derived from --preview-secret)").Envar("ONACMS_FORM_SECRET").String()
+		formDir      = kingpin.Flag("form-dir", "(optional) directory to store form submissions in (JSON lines, one file per form)").Envar("ONACMS_FORM_DIR").String()
+		smtpRelay    = kingpin.Flag("smtp-relay", "(optional) SMTP relay (host:port) to send form submissions to the recipients given in the form").Envar("ONACMS_SMTP_RELAY").String()
+		smtpFrom     = kingpin.Flag("smtp-from", "(optional) sender of form submissions sent by mail").Envar("ONACMS_SMTP_FROM").String()
+		smtpUsername = kingpin.Flag("smtp-username", "(optional) username for the SMTP relay").Envar("ONACMS_SMTP_USERNAME").String()
+		smtpPassword = kingpin.Flag("smtp-password", "(optional) password for the SMTP relay").Envar("ONACMS_SMTP_PASSWORD").String()
+
+		indexDir = kingpin.Flag("index-dir", "(optional) directory to keep the search index in, only nodes changed since the last start are reindexed (default: in memory)").Envar("ONACMS_INDEX_DIR").String()
+
+		healthPort    = kingpin.Flag("health-port", "(optional) TCP port for liveness and readiness probes, instead of the port(s) serving the site").Envar("ONACMS_HEALTH_PORT").Uint16()
+		livenessPath  = kingpin.Flag("liveness-path", "(optional) path of the liveness probe").Default("/livez").Envar("ONACMS_LIVENESS_PATH").String()
+		readinessPath = kingpin.Flag("readiness-path", "(optional) path of the readiness probe").Default("/readyz").Envar("ONACMS_READINESS_PATH").String()
 
 		logtimestamps = kingpin.Flag("log-timestamps", "include timestamps in logging , not required e.g. when using syslog)").Bool()
 
-		staticOutputDir = kingpin.Arg("Output", "do not start webserver, instead output site to <Output>").String()
+		serve = kingpin.Command("serve", "start webserver (default)").Default()
+
+		export          = kingpin.Command("export", "do not start webserver, instead output site to <Output>")
+		staticOutputDir = export.Arg("Output", "output directory").Required().String()
+		serverConfig    = export.Flag("server-config", "(optional) directory outside of <Output> to write the redirects and headers to as Apache httpd (apache.conf) and NGINX (nginx.conf) configuration").Envar("ONACMS_SERVER_CONFIG").String()
+		prettyURLs      = export.Flag("pretty-urls", "write nodes to <path>/index.html instead of <path>").Envar("ONACMS_PRETTY_URLS").Bool()
+
+		healthcheck = kingpin.Command("healthcheck", "probe the readiness of a running onacms, exit code 0 if ready (e.g. for Docker HEALTHCHECK)")
+		healthURL   = healthcheck.Flag("url", "(optional) URL to probe (default: the readiness path on the health port, or the first TCP listener or port of the server)").String()
 	)
 
-	kingpin.Parse()
+	args := os.Args[1:]
+	cmd, err := kingpin.CommandLine.Parse(args)
+	if err != nil {
+		// 'onacms [flags] <Output>' as before there were commands
+		if c, e := kingpin.CommandLine.Parse(append([]string{export.FullCommand()}, args...)); e == nil {
+			cmd, err, args = c, nil, append([]string{export.FullCommand()}, args...)
+		}
+	}
+	kingpin.FatalIfError(err, "")
+
+	set := setByUser(args)
+
+	if cmd == healthcheck.FullCommand() {
+		if *healthURL == "" {
+			*healthURL = healthcheckURL(*dir, set, *healthPort, *port, *listen, *tlsCert, *readinessPath)
+		}
+
+		if err := helpers.Healthcheck(*healthURL, 2*time.Second); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 
 	output := zerolog.ConsoleWriter{Out: os.Stdout}
 	if *logtimestamps {
@@ -49,6 +117,15 @@ func main() {
 
 	log := zerolog.New(output).With().Timestamp().Logger()
 
+	if *previewToken != 0 {
+		p := core.NewPreview(*previewSecret)
+		if p == nil {
+			log.Fatal().Msg("--preview-token requires --preview-secret")
+		}
+		fmt.Println(p.Token(time.Now().Add(*previewToken)))
+		os.Exit(0)
+	}
+
 	log.Info().Msg("onacms (C) THREATINT")
 
 	u, err := user.Current()
@@ -56,91 +133,358 @@ func main() {
 		log.Warn().Msg("please do not run as root")
 	}
 
+	// the Core serving the site, nil while loading
+	var loaded atomic.Pointer[core.Core]
+
+	var health *http.Server
+
+	if cmd == serve.FullCommand() && *healthPort != 0 {
+		// started before the site is loaded, so that the readiness probe reflects loading
+		mux := http.NewServeMux()
+		mux.Handle(*livenessPath, helpers.Liveness())
+		mux.Handle(*readinessPath, helpers.Readiness(loaded.Load))
+
+		log.Info().Msg(fmt.Sprintf("Liveness and readiness probes on port %v.", *healthPort))
+		health = &http.Server{Addr: fmt.Sprintf(":%v", *healthPort), Handler: mux, ReadTimeout: 2 * time.Second, WriteTimeout: 2 * time.Second}
+		go func() {
+			if err := health.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error().Msg(err.Error())
+			}
+		}()
+	}
+
 	fs := afero.NewBasePathFs(afero.NewOsFs(), *dir)
 
-	c := core.NewCore(&fs, log)
+	// the index on disk is locked by the server, so the static export keeps its own in memory
+	if cmd != serve.FullCommand() {
+		*indexDir = ""
+	}
+
+	c := core.NewCore(&fs, log, *indexDir)
 	if len(c.Nodes) == 0 {
 		log.Fatal().Msg("no nodes, exiting...")
 		os.Exit(0xe0)
 	}
 
-	if *staticOutputDir != "" {
-		for _, node := range c.Nodes {
-			if node.Enabled() {
-				p := filepath.Join(*staticOutputDir, string(node.Path()))
+	c.Preview = core.NewPreview(*previewSecret)
+	if c.Preview != nil {
+		log.Info().Msg("draft preview mode enabled")
+	}
 
-				m := fmt.Sprintf("%s...", p)
+	if *smtpRelay != "" && *smtpFrom == "" {
+		log.Fatal().Msg("--smtp-relay requires --smtp-from")
+	}
 
-				d := filepath.Dir(p)
+	if *formSecret == "" {
+		*formSecret = *previewSecret
+	}
+	c.SetFormSecret(*formSecret)
+	if *formSecret == "" && cmd == serve.FullCommand() {
+		for _, n := range c.Nodes {
+			if n.Form() != nil {
+				log.Warn().Msg("neither --form-secret nor --preview-secret set, forms rendered before a restart cannot be submitted afterwards")
+				break
+			}
+		}
+	}
 
-				if d != "." && d != ".." && d != string(os.PathSeparator) {
-					if err := os.MkdirAll(d, os.ModePerm); err != nil {
-						log.Error().Msg(fmt.Sprintf("%s Mkdir: %s", m, err.Error()))
-						os.Exit(0xf2)
-					}
-				}
+	c.Forms = core.NewForms(*formDir, *smtpRelay, *smtpFrom, *smtpUsername, *smtpPassword)
+	if *formDir != "" {
+		log.Info().Msg(fmt.Sprintf("storing form submissions in %s", *formDir))
+	}
+	if *smtpRelay != "" {
+		log.Info().Msg(fmt.Sprintf("sending form submissions via %s", *smtpRelay))
+	}
+	if cmd == serve.FullCommand() {
+		// every valid submission would fail
+		for _, n := range c.UndeliverableForms() {
+			log.Fatal().Msg(fmt.Sprintf("%s: form submissions can be neither stored (--form-dir) nor sent by mail (mail-to, --smtp-relay)", n.Path()))
+		}
+	}
 
-				f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY, os.ModePerm)
-				if err != nil {
-					log.Error().Msg(fmt.Sprintf("%s%s", m, err.Error()))
-					os.Exit(0xf1)
-				}
-				defer f.Close()
+	if cmd == export.FullCommand() {
+		if err := c.Export(*staticOutputDir, *serverConfig, *prettyURLs); err != nil {
+			log.Error().Msg(fmt.Sprintf("export: %s", err.Error()))
+			os.Exit(0xf2)
+		}
 
-				t := c.Templates[node.Template()]
-				if t == nil {
-					log.Warn().Msg(fmt.Sprintf("%s%s", m, err.Error()))
-				}
+		log.Info().Msg(fmt.Sprintf("site exported to %s", *staticOutputDir))
+		os.Exit(0)
+	}
 
-				context := core.Context{
-					Content: node.Render(),
-					Node:    node,
-				}
+	// server options: flags (and environment variables) take precedence over site.xml, followed by the defaults
+	if !set["listen"] {
+		*listen = c.Site.Listen()
+	}
+	*port = option(set["port"], *port, c.Site.Port(), 10000)
+	*socketMode = option(set["socket-mode"], *socketMode, c.Site.SocketMode(), "0660")
+	*socketOwner = option(set["socket-owner"], *socketOwner, c.Site.SocketOwner())
+	*tlsCert = option(set["tls-cert"], *tlsCert, c.Site.TLSCert())
+	*tlsKey = option(set["tls-key"], *tlsKey, c.Site.TLSKey())
+	*redirectPort = option(set["redirect-port"], *redirectPort, c.Site.RedirectPort())
+	// timeouts set to 0 in site.xml are not limited either
+	timeout := func(name string, flag time.Duration, site time.Duration, def time.Duration) time.Duration {
+		return option(set[name], flag, option(c.Site.TimeoutSet(name), site, def))
+	}
+	*readTimeout = timeout("read-timeout", *readTimeout, c.Site.ReadTimeout(), 2*time.Second)
+	*writeTimeout = timeout("write-timeout", *writeTimeout, c.Site.WriteTimeout(), 4*time.Second)
+	*idleTimeout = timeout("idle-timeout", *idleTimeout, c.Site.IdleTimeout(), 30*time.Second)
+	*handlerTimeout = timeout("handler-timeout", *handlerTimeout, c.Site.HandlerTimeout(), 4*time.Second)
+	*shutdownTimeout = timeout("shutdown-timeout", *shutdownTimeout, c.Site.ShutdownTimeout(), 30*time.Second)
+	*largeFileSize = option(set["large-file-size"], *largeFileSize, c.Site.LargeFileSize(), units.Mebibyte)
+	*accessLog = option(set["access-log"], *accessLog, c.Site.AccessLog())
+	*anonymiseIP = option(set["anonymise-ip"], *anonymiseIP, c.Site.AnonymiseIP())
+	*metricsPort = option(set["metrics-port"], *metricsPort, c.Site.MetricsPort())
+
+	if (*tlsCert == "") != (*tlsKey == "") {
+		log.Fatal().Msg("TLS certificate and key must be used together")
+	}
+
+	if *redirectPort != 0 && *tlsCert == "" {
+		log.Fatal().Msg("redirect port requires TLS certificate and key")
+	}
+
+	if *accessLog != "" && *accessLog != helpers.AccessLogJSON && *accessLog != helpers.AccessLogCombined {
+		log.Fatal().Msg(fmt.Sprintf("access log: unsupported format '%s', use json or combined", *accessLog))
+	}
+
+	mode, err := strconv.ParseUint(*socketMode, 8, 32)
+	if err != nil {
+		log.Fatal().Msg(fmt.Sprintf("socket mode: %s", err.Error()))
+	}
+
+	r := chi.NewRouter()
+
+	r.Use(helpers.Recoverer(&log))
+
+	if *healthPort == 0 {
+		r.Get(*livenessPath, helpers.Liveness().ServeHTTP)
+		r.Get(*readinessPath, helpers.Readiness(loaded.Load).ServeHTTP)
+	}
+
+	if api := c.Site.SearchAPI(); api != nil {
+		// served next to Core.HTTP, whose URL sanitising would redirect most query strings (same for suggestions)
+		log.Info().Msg(fmt.Sprintf("Search API enabled on %s.", api.Path()))
+		r.Get(api.Path(), c.SearchAPI)
+		r.Head(api.Path(), c.SearchAPI)
+	}
+
+	if api := c.Site.SuggestAPI(); api != nil {
+		log.Info().Msg(fmt.Sprintf("Suggestions enabled on %s.", api.Path()))
+		r.Get(api.Path(), c.SuggestAPI)
+		r.Head(api.Path(), c.SuggestAPI)
+	}
+
+	r.Get("/*", c.HTTP)
+	r.Head("/*", c.HTTP)
+	r.Post("/*", c.HTTP)
+
+	// downloads of large public files must not be cut off by the handler or write timeout
+	largeFile := func(r *http.Request) bool {
+		f := c.FindPublicFile(r.URL.Path)
+		return f != nil && int64(len(f.Content)) > int64(*largeFileSize)
+	}
+
+	// sockets passed by systemd take precedence (socket activation)
+	listeners, err := helpers.SystemdListeners()
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+
+	if len(listeners) == 0 {
+		addresses := *listen
+		if len(addresses) == 0 {
+			addresses = []string{fmt.Sprintf("tcp://:%v", *port)}
+		}
+
+		listeners, err = helpers.Listen(addresses, os.FileMode(mode), *socketOwner)
+		if err != nil {
+			log.Fatal().Msg(err.Error())
+		}
+	}
+
+	// HTTPS port to redirect to: the port of the first TCP listener
+	httpsPort := *port
+	for _, l := range listeners {
+		if a, ok := l.Addr().(*net.TCPAddr); ok {
+			httpsPort = uint16(a.Port)
+			break
+		}
+	}
+
+	handler := helpers.Timeout(r, *handlerTimeout, largeFile)
+
+	if *metricsPort != 0 {
+		// outside the timeout, so that requests that time out are counted as well
+		handler = helpers.Metrics(handler)
+	}
+
+	if *accessLog != "" {
+		// access log goes to stdout, one line per request, no level; outside the timeout, so that requests
+		// that time out are logged as well
+		var al zerolog.Logger
+		if *accessLog == helpers.AccessLogCombined {
+			al = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, NoColor: true, PartsOrder: []string{zerolog.MessageFieldName}})
+		} else {
+			al = zerolog.New(os.Stdout)
+		}
 
-				for {
-					var buf bytes.Buffer
-					gt := template.New(t.Name())
-					gt, err = gt.Parse(t.Content())
-					if err != nil {
-						log.Error().Msg(fmt.Sprintf("%s%s", m, err.Error()))
-						break
-					}
-
-					err = gt.Execute(&buf, &context)
-					if err != nil {
-						log.Error().Msg(fmt.Sprintf("%s%s", m, err.Error()))
-						break
-					}
-
-					context.Content = buf.String()
-
-					if t.Parent() == "" {
-						break
-					}
-					t = c.Templates[t.Parent()]
+		log.Info().Msg(fmt.Sprintf("Access log enabled (%s).", *accessLog))
+		handler = helpers.AccessLog(&al, *accessLog, *anonymiseIP)(handler)
+	}
+
+	server := &http.Server{
+		Handler:      handler,
+		ReadTimeout:  *readTimeout,
+		WriteTimeout: *writeTimeout,
+		IdleTimeout:  *idleTimeout,
+	}
+
+	var redirect *http.Server
+
+	if *tlsCert != "" {
+		certs, err := helpers.NewCertificateReloader(*tlsCert, *tlsKey, &log)
+		if err != nil {
+			log.Fatal().Msg(err.Error())
+		}
+		go certs.Watch(time.Minute)
+
+		// HTTP/2 is enabled automatically for TLS
+		server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		}
+
+		if *redirectPort != 0 {
+			log.Info().Msg(fmt.Sprintf("Redirecting HTTP on port %v to HTTPS.", *redirectPort))
+			redirect = &http.Server{Addr: fmt.Sprintf(":%v", *redirectPort), Handler: helpers.RedirectToHTTPS(httpsPort), ReadTimeout: *readTimeout, WriteTimeout: *writeTimeout, IdleTimeout: *idleTimeout}
+			go func() {
+				if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.Error().Msg(err.Error())
 				}
+			}()
+		}
+	}
 
-				f.WriteString(context.Content)
+	var metrics *http.Server
 
-				log.Info().Msg(fmt.Sprintf("%s ok", m))
+	if *metricsPort != 0 {
+		// separate listener, so that metrics are not exposed to the public
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", helpers.MetricsHandler())
+
+		log.Info().Msg(fmt.Sprintf("Exposing metrics on port %v.", *metricsPort))
+		metrics = &http.Server{Addr: fmt.Sprintf(":%v", *metricsPort), Handler: mux, ReadTimeout: *readTimeout, WriteTimeout: *writeTimeout, IdleTimeout: *idleTimeout}
+		go func() {
+			if err := metrics.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error().Msg(err.Error())
 			}
-		}
+		}()
+	}
 
-		os.Exit(0)
+	loaded.Store(c)
+
+	done := helpers.ShutdownOnSignal(*shutdownTimeout, &log, server, redirect, metrics, health)
+
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			if *tlsCert == "" {
+				// default: plain HTTP behind a frontend webserver like NGINX
+				log.Info().Msg(fmt.Sprintf("Running on %s://%s.", l.Addr().Network(), l.Addr().String()))
+				errs <- server.Serve(l)
+			} else {
+				log.Info().Msg(fmt.Sprintf("Running on %s://%s (TLS).", l.Addr().Network(), l.Addr().String()))
+				errs <- server.ServeTLS(l, "", "")
+			}
+		}(l)
 	}
 
-	r := chi.NewRouter()
+	if err := <-errs; err != http.ErrServerClosed {
+		log.Fatal().Msg(err.Error())
+	}
 
-	r.Use(middleware.Timeout(time.Second * 10))
+	// wait for active connections to finish
+	<-done
 
-	r.Use(middleware.Compress(9))
+	if err := c.Close(); err != nil {
+		log.Error().Msg(err.Error())
+	}
+	log.Info().Msg("bye")
+}
 
-	r.Use(helpers.Recoverer(&log))
+// healthcheckURL return URL of the readiness probe of an onacms running with the same flags, environment variables
+// and site.xml: the health port if set, otherwise the first TCP listener (HTTPS if TLS is enabled)
+func healthcheckURL(dir string, set map[string]bool, healthPort uint16, port uint16, listen []string, tlsCert string, path string) string {
+	if healthPort != 0 {
+		return fmt.Sprintf("http://127.0.0.1:%v%s", healthPort, path)
+	}
 
-	r.Get("/*", c.HTTP)
+	site := &core.Site{}
+	if b, err := afero.ReadFile(afero.NewBasePathFs(afero.NewOsFs(), dir), "site.xml"); err == nil {
+		// site.xml is optional, errors are reported by the server
+		site.Read(b)
+	}
+
+	if !set["listen"] {
+		listen = site.Listen()
+	}
+
+	scheme := "http"
+	if option(set["tls-cert"], tlsCert, site.TLSCert()) != "" {
+		scheme = "https"
+	}
+
+	host, p := "127.0.0.1", strconv.Itoa(int(option(set["port"], port, site.Port(), 10000)))
+	for _, a := range listen {
+		if u, err := url.Parse(a); err == nil && strings.HasPrefix(u.Scheme, "tcp") {
+			// probe the address listened on unless it is the unspecified address (all interfaces)
+			if ip := net.ParseIP(u.Hostname()); u.Hostname() != "" && (ip == nil || !ip.IsUnspecified()) {
+				host = u.Hostname()
+			}
+			p = u.Port()
+			break
+		}
+	}
 
-	log.Info().Msg(fmt.Sprintf("Running on port %v.", *port))
-	server := &http.Server{Addr: fmt.Sprintf(":%v", *port), Handler: http.TimeoutHandler(r, 4*time.Second, ""), ReadTimeout: time.Second * 2, WriteTimeout: time.Second * 4}
+	return fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(host, p), path)
+}
+
+// setByUser return names of the flags given in args or by their environment variable
+func setByUser(args []string) map[string]bool {
+	set := make(map[string]bool)
+
+	if ctx, err := kingpin.CommandLine.ParseContext(args); err == nil {
+		for _, e := range ctx.Elements {
+			if f, ok := e.Clause.(*kingpin.FlagClause); ok {
+				set[f.Model().Name] = true
+			}
+		}
+	}
+
+	for _, f := range kingpin.CommandLine.Model().Flags {
+		if _, ok := os.LookupEnv(f.Envar); ok && f.Envar != "" {
+			set[f.Name] = true
+		}
+	}
+
+	return set
+}
+
+// option return value if it is set by the user, even if it is the zero value (e.g. --metrics-port=0 disables the
+// metrics enabled in site.xml), otherwise the first of values that is set (= not the zero value)
+func option[T comparable](set bool, value T, values ...T) T {
+	if set {
+		return value
+	}
+
+	var zero T
+
+	for _, v := range values {
+		if v != zero {
+			return v
+		}
+	}
 
-	server.ListenAndServe()
+	return zero
 }